@@ -0,0 +1,92 @@
+// Package apiversion mounts the API under versioned path prefixes.
+//
+// Handlers always speak the canonical shapes in package models. A Version
+// may carry a Transformer that rewrites JSON request bodies into the
+// canonical shape and JSON responses back into the version's own shape, so a
+// v2 Todo can be served by the same handlers as v1:
+//
+//	v2 := apiversion.Version{Name: "v2", Transformer: apiversion.Transformer{
+//		Request:  apiversion.RenameKeys(map[string]string{"user_id": "userId"}),
+//		Response: apiversion.RenameKeys(map[string]string{"userId": "user_id"}),
+//	}}
+//	v2.Mount(r, registerRoutes)
+//
+// Only application/json bodies are transformed. Problem responses, event
+// streams and WebSocket traffic pass through unchanged.
+package apiversion
+
+import (
+	"ToDoProject/decode"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+
+	mux "github.com/gorilla/mux"
+)
+
+// TransformFunc rewrites a JSON document.
+type TransformFunc func(body []byte) ([]byte, error)
+
+// Transformer converts between a version's wire format and the canonical
+// one. Either direction may be nil to leave it untouched.
+type Transformer struct {
+	Request  TransformFunc
+	Response TransformFunc
+}
+
+// Version is one API version served under /{Name}.
+type Version struct {
+	Name        string
+	Transformer Transformer
+}
+
+// V1 is the current API; its shapes are the canonical ones.
+var V1 = Version{Name: "v1"}
+
+// Mount registers routes on a subrouter under /{Name} wrapped in the
+// version's middleware and returns the subrouter.
+func (v Version) Mount(r *mux.Router, routes func(*mux.Router)) *mux.Router {
+	sub := r.PathPrefix("/" + v.Name).Subrouter()
+	sub.Use(v.Middleware)
+	routes(sub)
+	return sub
+}
+
+// Middleware sets the API-Version response header and applies the
+// version's transformer.
+func (v Version) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", v.Name)
+
+		if v.Transformer.Request != nil && r.Body != nil && isJSON(r.Header.Get("Content-Type")) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, decode.MaxBodyBytes))
+			if err != nil {
+				decode.JSONError(w, r, decode.ErrBodyTooLarge, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if len(bytes.TrimSpace(body)) > 0 {
+				body, err = v.Transformer.Request(body)
+				if err != nil {
+					decode.JSONError(w, r, err, http.StatusBadRequest)
+					return
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+		}
+
+		if v.Transformer.Response == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tw := &transformWriter{ResponseWriter: w, transform: v.Transformer.Response}
+		next.ServeHTTP(tw, r)
+		tw.finish(r)
+	})
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json"
+}
@@ -0,0 +1,33 @@
+package apiversion
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Deprecation marks a set of routes as deprecated.
+type Deprecation struct {
+	// Since is sent as the Deprecation header (RFC 9745).
+	Since time.Time
+	// Sunset is sent as the Sunset header (RFC 8594); zero omits it.
+	Sunset time.Time
+	// Successor is the path prefix of the replacement routes, e.g. "/v1".
+	// It is advertised in a successor-version Link header.
+	Successor string
+}
+
+// Middleware adds the deprecation headers to every response.
+func (d Deprecation) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+		if !d.Sunset.IsZero() {
+			h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if d.Successor != "" {
+			h.Add("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", d.Successor, r.URL.Path))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
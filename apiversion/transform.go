@@ -0,0 +1,119 @@
+package apiversion
+
+import (
+	"ToDoProject/decode"
+	"ToDoProject/logging"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// transformWriter buffers JSON responses so they can be transformed once the
+// handler is done. Any other response is streamed through as is.
+type transformWriter struct {
+	http.ResponseWriter
+	transform   TransformFunc
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+}
+
+func (w *transformWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	w.buffering = isJSON(w.Header().Get("Content-Type"))
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *transformWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *transformWriter) Flush() {
+	if w.buffering {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *transformWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("apiversion: response does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish transforms and writes a buffered response.
+func (w *transformWriter) finish(r *http.Request) {
+	if !w.buffering {
+		return
+	}
+	body := w.buf.Bytes()
+	if len(bytes.TrimSpace(body)) > 0 {
+		var err error
+		body, err = w.transform(body)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("transform response", "error", err)
+			decode.ProblemResponse(w.ResponseWriter, r, decode.Problem{Status: http.StatusInternalServerError})
+			return
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
+
+// RenameKeys returns a TransformFunc that renames object members anywhere in
+// the document, e.g. to switch a field between camelCase and snake_case.
+func RenameKeys(renames map[string]string) TransformFunc {
+	return func(body []byte) ([]byte, error) {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		return json.Marshal(renameKeys(doc, renames))
+	}
+}
+
+func renameKeys(v interface{}, renames map[string]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if renamed, ok := renames[key]; ok {
+				key = renamed
+			}
+			out[key] = renameKeys(value, renames)
+		}
+		return out
+	case []interface{}:
+		for i := range v {
+			v[i] = renameKeys(v[i], renames)
+		}
+		return v
+	}
+	return v
+}
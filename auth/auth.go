@@ -0,0 +1,72 @@
+// Package auth describes who a request is made by and what they may do.
+// AuthMiddleware in jwttoken puts a Principal into the request context;
+// routes declare the Requirements their callers must meet.
+package auth
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"slices"
+	"time"
+)
+
+// TokenType tells how a principal authenticated.
+type TokenType string
+
+const (
+	// AccessToken is a JWT from /login or /refresh. It carries every scope
+	// and the user's roles as of when it was issued.
+	AccessToken TokenType = "access"
+	// PersonalToken is a personal access token. It carries the scopes it
+	// was created with and no roles.
+	PersonalToken TokenType = "personal"
+)
+
+// Roles a user can be granted.
+const (
+	RoleAdmin = "admin"
+)
+
+// Roles lists every role.
+var Roles = []string{RoleAdmin}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID    int
+	Roles     []string
+	Scopes    []string
+	TokenType TokenType
+	// ExpiresAt is when the token expires; it is zero for tokens that do
+	// not. Long-lived connections end then.
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the principal's token carries scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// HasRole reports whether the principal has role.
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// AccessPrincipal returns the principal of an access token, which carries
+// every scope.
+func AccessPrincipal(userID int, roles []string) Principal {
+	return Principal{UserID: userID, Roles: roles, Scopes: models.Scopes, TokenType: AccessToken}
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal ctx carries. ok is false outside of
+// authenticated requests.
+func FromContext(ctx context.Context) (p Principal, ok bool) {
+	p, ok = ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
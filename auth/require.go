@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"ToDoProject/decode"
+	"ToDoProject/store"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnauthenticated is returned for requests without a principal.
+var ErrUnauthenticated = errors.New("not authenticated")
+
+// Requirement checks that a principal may use a route. It returns an error
+// matching store.ErrForbidden when they may not.
+type Requirement func(p Principal) error
+
+// Check returns the error of the first requirement p does not meet.
+func Check(p Principal, reqs ...Requirement) error {
+	for _, req := range reqs {
+		if err := req(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScopeError is returned when a principal's token lacks a scope.
+type ScopeError struct {
+	Scope string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("token lacks the %s scope", e.Scope)
+}
+
+func (e *ScopeError) Is(target error) bool {
+	return target == store.ErrForbidden
+}
+
+// Scope requires the token to carry scope.
+func Scope(scope string) Requirement {
+	return func(p Principal) error {
+		if !p.HasScope(scope) {
+			return &ScopeError{Scope: scope}
+		}
+		return nil
+	}
+}
+
+// Role requires the principal to have role.
+func Role(role string) Requirement {
+	return func(p Principal) error {
+		if !p.HasRole(role) {
+			return store.Forbidden("requires the %s role", role)
+		}
+		return nil
+	}
+}
+
+// Require rejects requests whose principal does not meet every
+// requirement: with 401 when there is no principal, so it must run after
+// jwttoken.AuthMiddleware, and with 403 otherwise.
+func Require(reqs ...Requirement) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			if !ok {
+				decode.Unauthorized(w, r, ErrUnauthenticated)
+				return
+			}
+			err := Check(p, reqs...)
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var scopeErr *ScopeError
+			if errors.As(err, &scopeErr) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scopeErr.Scope))
+			}
+			decode.ProblemResponse(w, r, decode.Problem{
+				Type:   decode.ProblemTypeForbidden,
+				Title:  "Permission denied",
+				Status: http.StatusForbidden,
+				Detail: err.Error(),
+			})
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"net/http"
+)
+
+// AuthResult is the response of Login and Register.
+type AuthResult struct {
+	UserID int `json:"user_id"`
+	Tokens
+}
+
+// Register creates a user and signs the client in as that user.
+func (c *Client) Register(ctx context.Context, username, password string) (AuthResult, error) {
+	return c.authenticate(ctx, "/register", username, password)
+}
+
+// Login signs the client in.
+func (c *Client) Login(ctx context.Context, username, password string) (AuthResult, error) {
+	return c.authenticate(ctx, "/login", username, password)
+}
+
+func (c *Client) authenticate(ctx context.Context, path, username, password string) (AuthResult, error) {
+	var res AuthResult
+	err := c.do(ctx, request{
+		method:  http.MethodPost,
+		path:    path,
+		body:    models.LoginRequest{Username: username, Password: password},
+		public:  true,
+		noRetry: true,
+	}, &res)
+	if err != nil {
+		return AuthResult{}, err
+	}
+	c.setTokens(res.Tokens)
+	return res, nil
+}
+
+// Refresh exchanges the refresh token for a new access token. The client
+// calls it by itself when a request is rejected with 401.
+func (c *Client) Refresh(ctx context.Context) error {
+	current := c.Tokens()
+	var res Tokens
+	err := c.do(ctx, request{
+		method:  http.MethodPost,
+		path:    "/refresh",
+		body:    models.RefreshRequest{RefreshToken: current.RefreshToken},
+		public:  true,
+		noRetry: true,
+	}, &res)
+	if err != nil {
+		return err
+	}
+	if res.RefreshToken == "" {
+		res.RefreshToken = current.RefreshToken
+	}
+	c.setTokens(res)
+	return nil
+}
+
+// refreshIfStale refreshes the tokens unless another request already did so
+// since used was sent, so concurrent 401s cause a single refresh.
+func (c *Client) refreshIfStale(ctx context.Context, used string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.Tokens().AccessToken != used {
+		return nil
+	}
+	return c.Refresh(ctx)
+}
@@ -0,0 +1,223 @@
+// Package client is a Go client for the todo API.
+//
+//	c, err := client.New("http://localhost:8080/v1")
+//	if err != nil { ... }
+//	if _, err := c.Login(ctx, "alice", "secure-passw0rd"); err != nil { ... }
+//	for todo, err := range c.Todos(ctx, client.ListOptions{Done: &done}) { ... }
+//
+// The client attaches the access token to every request, refreshes it with
+// the refresh token when the API answers 401, and retries idempotent
+// requests with exponential backoff on transient failures. Error responses
+// are returned as *APIError, which matches ErrNotFound, ErrConflict and the
+// other sentinels with errors.Is.
+//
+// The WebSocket and gRPC interfaces are not covered; use the generated
+// todopb client for gRPC.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Tokens are the credentials returned by login and registration.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Client talks to one API server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retry      RetryPolicy
+	onTokens   func(Tokens)
+
+	mu        sync.Mutex
+	tokens    Tokens
+	refreshMu sync.Mutex
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client. The default is
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTokens starts the client with previously obtained tokens. A personal
+// access token goes in AccessToken, without a RefreshToken.
+func WithTokens(t Tokens) Option {
+	return func(c *Client) { c.tokens = t }
+}
+
+// WithTokenHook registers fn to be called whenever the client obtains new
+// tokens, e.g. to persist them.
+func WithTokenHook(fn func(Tokens)) Option {
+	return func(c *Client) { c.onTokens = fn }
+}
+
+// WithRetry replaces DefaultRetryPolicy.
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// New returns a client for the API at baseURL, including the version
+// prefix, e.g. "https://todo.example.com/v1".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("client: base URL %q must be absolute", baseURL)
+	}
+	c := &Client{baseURL: u, httpClient: http.DefaultClient, retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Tokens returns the client's current tokens.
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+func (c *Client) setTokens(t Tokens) {
+	c.mu.Lock()
+	c.tokens = t
+	c.mu.Unlock()
+	if c.onTokens != nil {
+		c.onTokens(t)
+	}
+}
+
+// request describes one API call.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        interface{}
+	contentType string
+	// public requests are sent without a token and never refreshed.
+	public bool
+	// noRetry requests are sent once. Auth endpoints use it, since their
+	// 429 means the account is locked, which retrying cannot lift.
+	noRetry bool
+}
+
+// do sends req and decodes a successful JSON response into out, which may be
+// nil.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send performs req with retries and a single token refresh, and returns
+// the response if its status is 2xx. Otherwise the body is decoded into an
+// *APIError.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("client: encode %s %s request: %w", req.method, req.path, err)
+		}
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		token := c.Tokens().AccessToken
+		resp, err := c.attempt(ctx, req, payload, token)
+
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !req.public && !refreshed && c.Tokens().RefreshToken != "" {
+			drain(resp)
+			refreshed = true
+			if err := c.refreshIfStale(ctx, token); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		}
+
+		if wait, ok := c.retry.next(attempt, req.method, resp, err); ok && !req.noRetry {
+			if resp != nil {
+				drain(resp)
+			}
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request, payload []byte, token string) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.header {
+		httpReq.Header[key] = values
+	}
+	if payload != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	if !req.public && token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(httpReq)
+}
+
+// drain discards the rest of the body so the connection can be reused.
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for an httptest server running h, with
+// retry delays short enough for tests.
+func newTestClient(t *testing.T, h http.Handler, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	opts = append([]Option{WithRetry(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond})}, opts...)
+	c, err := New(srv.URL+"/v1", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	var refreshes atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/refresh", func(w http.ResponseWriter, r *http.Request) {
+		var req models.RefreshRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.RefreshToken != "refresh-1" {
+			t.Errorf("refresh token = %q, want refresh-1", req.RefreshToken)
+		}
+		refreshes.Add(1)
+		writeJSON(w, http.StatusOK, Tokens{AccessToken: "access-2"})
+	})
+	mux.HandleFunc("GET /v1/todos/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-2" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"title": "Unauthorized"})
+			return
+		}
+		writeJSON(w, http.StatusOK, models.Todo{ID: 1, Title: "milk"})
+	})
+
+	var hooked []Tokens
+	c := newTestClient(t, mux,
+		WithTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"}),
+		WithTokenHook(func(tok Tokens) { hooked = append(hooked, tok) }),
+	)
+	todo, err := c.GetTodo(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Title != "milk" {
+		t.Errorf("title = %q, want milk", todo.Title)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("refreshes = %d, want 1", n)
+	}
+	want := Tokens{AccessToken: "access-2", RefreshToken: "refresh-1"}
+	if got := c.Tokens(); got != want {
+		t.Errorf("tokens = %+v, want %+v", got, want)
+	}
+	if len(hooked) != 1 || hooked[0] != want {
+		t.Errorf("hook got %+v, want [%+v]", hooked, want)
+	}
+}
+
+func TestRefreshOnlyOnce(t *testing.T) {
+	var refreshes, gets atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/refresh", func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		writeJSON(w, http.StatusOK, Tokens{AccessToken: "access-2"})
+	})
+	mux.HandleFunc("GET /v1/todos/1", func(w http.ResponseWriter, r *http.Request) {
+		gets.Add(1)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"title": "Unauthorized"})
+	})
+
+	c := newTestClient(t, mux, WithTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"}))
+	_, err := c.GetTodo(context.Background(), 1)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("refreshes = %d, want 1", n)
+	}
+	if n := gets.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestNoRefreshWithoutRefreshToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/refresh", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("refresh called for a personal access token")
+	})
+	mux.HandleFunc("GET /v1/todos/1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"title": "Unauthorized"})
+	})
+
+	c := newTestClient(t, mux, WithTokens(Tokens{AccessToken: "pat"}))
+	if _, err := c.GetTodo(context.Background(), 1); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestLoginStoresTokens(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("login sent an Authorization header")
+		}
+		writeJSON(w, http.StatusOK, AuthResult{UserID: 7, Tokens: Tokens{AccessToken: "a", RefreshToken: "r"}})
+	})
+
+	c := newTestClient(t, mux, WithTokens(Tokens{AccessToken: "old"}))
+	res, err := c.Login(context.Background(), "alice", "secure-passw0rd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UserID != 7 {
+		t.Errorf("user id = %d, want 7", res.UserID)
+	}
+	if got := c.Tokens(); got != (Tokens{AccessToken: "a", RefreshToken: "r"}) {
+		t.Errorf("tokens = %+v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"ToDoProject/decode"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Sentinels matched by *APIError with errors.Is.
+var (
+	ErrValidation           = errors.New("validation failed")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrConfirmationRequired = errors.New("confirmation required")
+	ErrRateLimited          = errors.New("rate limited")
+)
+
+// APIError is an error response of the API. The embedded problem details
+// are filled from the response body; for responses without one, Title and
+// Detail fall back to the status text.
+type APIError struct {
+	StatusCode int
+	decode.Problem
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("todo api: %d %s: %s", e.StatusCode, e.Title, e.Detail)
+	}
+	return fmt.Sprintf("todo api: %d %s", e.StatusCode, e.Title)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrValidation:
+		return e.Type == decode.ProblemTypeValidation || e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrConfirmationRequired:
+		return e.StatusCode == http.StatusPreconditionRequired
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(body, &e.Problem); err != nil && len(body) > 0 {
+		e.Detail = string(body)
+	}
+	e.Status = resp.StatusCode
+	if e.Title == "" {
+		e.Title = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
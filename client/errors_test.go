@@ -0,0 +1,73 @@
+package client
+
+import (
+	"ToDoProject/decode"
+	models "ToDoProject/models"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrValidation},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusPreconditionRequired, ErrConfirmationRequired},
+		{http.StatusTooManyRequests, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, tt.status, decode.Problem{Title: http.StatusText(tt.status), Status: tt.status})
+		}), WithRetry(RetryPolicy{}))
+		_, err := c.GetTodo(context.Background(), 1)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%d: err = %v, want %v", tt.status, err, tt.want)
+		}
+		if tt.want != ErrNotFound && errors.Is(err, ErrNotFound) {
+			t.Errorf("%d: err matches ErrNotFound", tt.status)
+		}
+	}
+}
+
+func TestAPIErrorProblem(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnprocessableEntity, decode.Problem{
+			Type:   decode.ProblemTypeValidation,
+			Title:  "Validation failed",
+			Status: http.StatusUnprocessableEntity,
+			Errors: []models.FieldError{{Field: "title", Message: "is required"}},
+		})
+	}))
+	_, err := c.CreateTodo(context.Background(), models.TodoHandlerRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if !errors.Is(err, ErrValidation) {
+		t.Error("validation problem does not match ErrValidation")
+	}
+	if len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != "title" {
+		t.Errorf("field errors = %+v", apiErr.Errors)
+	}
+}
+
+func TestAPIErrorWithoutProblem(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream went away", http.StatusNotFound)
+	}))
+	_, err := c.GetTodo(context.Background(), 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.Title != "Not Found" || apiErr.Detail != "upstream went away\n" {
+		t.Errorf("title = %q, detail = %q", apiErr.Title, apiErr.Detail)
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StreamEvents follows GET /todos/events and calls fn for every event
+// until ctx is cancelled, the stream ends or fn returns an error. Pass the
+// ID of the last event seen as afterID to resume after a disconnect.
+func (c *Client) StreamEvents(ctx context.Context, afterID int64, fn func(models.TodoEvent) error) error {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if afterID > 0 {
+		header.Set("Last-Event-ID", strconv.FormatInt(afterID, 10))
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/todos/events", header: header})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var event models.TodoEvent
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			if line != "" || data.Len() == 0 {
+				// Comment such as the heartbeat, or a blank line without data.
+				continue
+			}
+			if err := json.Unmarshal([]byte(data.String()), &event.Payload); err != nil {
+				return err
+			}
+			event.TodoId = event.Payload.ID
+			event.UserId = event.Payload.UserId
+			if err := fn(event); err != nil {
+				return err
+			}
+			event = models.TodoEvent{}
+			data.Reset()
+		case "id":
+			event.ID, _ = strconv.ParseInt(value, 10, 64)
+		case "event":
+			event.Type = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failing answers the first n requests with status and the rest with 200.
+func failing(n int32, status int, header http.Header, calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			writeJSON(w, status, map[string]string{"title": http.StatusText(status)})
+			return
+		}
+		writeJSON(w, http.StatusOK, models.Todo{ID: 1})
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(2, http.StatusServiceUnavailable, nil, &calls))
+	if _, err := c.GetTodo(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(100, http.StatusBadGateway, nil, &calls))
+	_, err := c.GetTodo(context.Background(), 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("err = %v, want a 502 *APIError", err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("requests = %d, want 4", n)
+	}
+}
+
+func TestNoRetryForNonIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(1, http.StatusServiceUnavailable, nil, &calls))
+	if _, err := c.CreateTodo(context.Background(), models.TodoHandlerRequest{Title: "milk"}); err == nil {
+		t.Fatal("want an error")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestRetryTooManyRequests(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}}, &calls))
+	if _, err := c.CreateTodo(context.Background(), models.TodoHandlerRequest{Title: "milk"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestNoRetryBeyondMaxDelay(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"900"}}, &calls))
+	start := time.Now()
+	_, err := c.GetTodo(context.Background(), 1)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want no wait", elapsed)
+	}
+}
+
+func TestNoRetryForLogin(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}}, &calls))
+	if _, err := c.Login(context.Background(), "alice", "wrong"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, failing(100, http.StatusServiceUnavailable, nil, &calls),
+		WithRetry(RetryPolicy{MaxRetries: 10, BaseDelay: time.Hour, MaxDelay: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetTodo(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 20 {
+			if d := p.backoff(attempt); d < limit/2 || d >= limit {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v)", attempt, d, limit/2, limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Pull returns the changes since token, or a snapshot of every todo when
+// token is empty. Call it again with the returned token while HasMore is
+// true.
+func (c *Client) Pull(ctx context.Context, token string) (models.SyncPullResponse, error) {
+	q := url.Values{}
+	if token != "" {
+		q.Set("since", token)
+	}
+	var res models.SyncPullResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/sync", query: q}, &res)
+	return res, err
+}
+
+// Push applies changes made while offline. Changes that could not be
+// applied are reported in the response's Conflicts, not as an error.
+func (c *Client) Push(ctx context.Context, req models.SyncPushRequest) (models.SyncPushResponse, error) {
+	var res models.SyncPushResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/sync", body: req}, &res)
+	return res, err
+}
+
+// GraphQLError is one entry of the errors of a GraphQL response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLErrors is returned by GraphQL when the response has errors. Data
+// that was resolved is still decoded.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	if len(e) == 1 {
+		return "graphql: " + e[0].Message
+	}
+	return "graphql: " + e[0].Message + " (and more errors)"
+}
+
+// GraphQL runs a query or mutation and decodes its data into out.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	var res struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/graphql",
+		body:   models.GraphQLRequest{Query: query, Variables: variables},
+	}, &res)
+	if err != nil {
+		return err
+	}
+	if out != nil && len(res.Data) > 0 {
+		if err := json.Unmarshal(res.Data, out); err != nil {
+			return err
+		}
+	}
+	if len(res.Errors) > 0 {
+		return res.Errors
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Filter selects todos by the same fields as the query parameters of
+// GET /todos. Zero fields do not filter.
+type Filter struct {
+	Done          *bool
+	Title         string
+	Description   string
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+}
+
+func (f Filter) values() url.Values {
+	q := url.Values{}
+	if f.Done != nil {
+		q.Set("done", strconv.FormatBool(*f.Done))
+	}
+	if f.Title != "" {
+		q.Set("title", f.Title)
+	}
+	if f.Description != "" {
+		q.Set("description", f.Description)
+	}
+	if !f.CreatedBefore.IsZero() {
+		q.Set("created_before", f.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if !f.CreatedAfter.IsZero() {
+		q.Set("created_after", f.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	return q
+}
+
+// ListOptions filters and pages GET /todos.
+type ListOptions struct {
+	Filter
+	// Limit and Offset page the result; zero Limit returns every todo.
+	Limit  int
+	Offset int
+	// PageSize is the page size used by Todos; it defaults to 100.
+	PageSize int
+}
+
+func (c *Client) CreateTodo(ctx context.Context, req models.TodoHandlerRequest) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{method: http.MethodPost, path: "/todos", body: req}, &todo)
+	return todo, err
+}
+
+func (c *Client) GetTodo(ctx context.Context, id int) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{method: http.MethodGet, path: todoPath(id)}, &todo)
+	return todo, err
+}
+
+// ListTodos returns one page of todos in id order.
+func (c *Client) ListTodos(ctx context.Context, opts ListOptions) ([]models.Todo, error) {
+	q := opts.values()
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	var todos []models.Todo
+	err := c.do(ctx, request{method: http.MethodGet, path: "/todos", query: q}, &todos)
+	return todos, err
+}
+
+// Todos iterates over every todo matching opts.Filter, fetching them page
+// by page. Iteration stops after the first error.
+func (c *Client) Todos(ctx context.Context, opts ListOptions) iter.Seq2[models.Todo, error] {
+	return func(yield func(models.Todo, error) bool) {
+		size := opts.PageSize
+		if size <= 0 {
+			size = 100
+		}
+		page := ListOptions{Filter: opts.Filter, Limit: size}
+		for {
+			todos, err := c.ListTodos(ctx, page)
+			if err != nil {
+				yield(models.Todo{}, err)
+				return
+			}
+			for _, todo := range todos {
+				if !yield(todo, nil) {
+					return
+				}
+			}
+			if len(todos) < size {
+				return
+			}
+			page.Offset += len(todos)
+		}
+	}
+}
+
+// ReplaceTodo sets every field of the todo, like PUT /todos/{id}.
+func (c *Client) ReplaceTodo(ctx context.Context, id int, req models.TodoUpdateHandlerRequest) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{method: http.MethodPut, path: todoPath(id), body: req}, &todo)
+	return todo, err
+}
+
+// UpdateTodo changes the non-nil fields of req.
+func (c *Client) UpdateTodo(ctx context.Context, id int, req models.TodoUpdateHandlerRequest) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{method: http.MethodPatch, path: todoPath(id), body: req}, &todo)
+	return todo, err
+}
+
+// MergePatchTodo applies a JSON Merge Patch (RFC 7396) to the todo.
+func (c *Client) MergePatchTodo(ctx context.Context, id int, patch map[string]interface{}) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{
+		method:      http.MethodPatch,
+		path:        todoPath(id),
+		body:        patch,
+		contentType: "application/merge-patch+json",
+	}, &todo)
+	return todo, err
+}
+
+// PatchOperation is one operation of a JSON Patch (RFC 6902).
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// JSONPatchTodo applies a JSON Patch to the todo. A failing "test"
+// operation returns an error matching ErrConflict.
+func (c *Client) JSONPatchTodo(ctx context.Context, id int, ops []PatchOperation) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{
+		method:      http.MethodPatch,
+		path:        todoPath(id),
+		body:        ops,
+		contentType: "application/json-patch+json",
+	}, &todo)
+	return todo, err
+}
+
+// DeleteTodo deletes the todo and returns it.
+func (c *Client) DeleteTodo(ctx context.Context, id int) (models.Todo, error) {
+	var todo models.Todo
+	err := c.do(ctx, request{method: http.MethodDelete, path: todoPath(id)}, &todo)
+	return todo, err
+}
+
+// BulkOptions control bulk updates and deletes.
+type BulkOptions struct {
+	// DryRun only counts the matching todos.
+	DryRun bool
+	// ConfirmCount is sent as X-Confirm-Count. It is required when more
+	// todos match than the server's threshold; the error is then
+	// ErrConfirmationRequired.
+	ConfirmCount int
+}
+
+func (o BulkOptions) apply(q url.Values) http.Header {
+	if o.DryRun {
+		q.Set("dry_run", "true")
+	}
+	header := http.Header{}
+	if o.ConfirmCount > 0 {
+		header.Set("X-Confirm-Count", strconv.Itoa(o.ConfirmCount))
+	}
+	return header
+}
+
+// BulkUpdate applies req to every todo matching filter.
+func (c *Client) BulkUpdate(ctx context.Context, filter Filter, req models.TodoUpdateHandlerRequest, opts BulkOptions) (models.BulkResult, error) {
+	q := filter.values()
+	var res models.BulkResult
+	err := c.do(ctx, request{method: http.MethodPatch, path: "/todos", query: q, header: opts.apply(q), body: req}, &res)
+	return res, err
+}
+
+// BulkDelete deletes every todo matching filter.
+func (c *Client) BulkDelete(ctx context.Context, filter Filter, opts BulkOptions) (models.BulkResult, error) {
+	q := filter.values()
+	var res models.BulkResult
+	err := c.do(ctx, request{method: http.MethodDelete, path: "/todos", query: q, header: opts.apply(q)}, &res)
+	return res, err
+}
+
+// Batch runs several operations in one request; see POST /todos/batch.
+func (c *Client) Batch(ctx context.Context, req models.BatchRequest) (models.BatchResponse, error) {
+	var res models.BatchResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/todos/batch", body: req}, &res)
+	return res, err
+}
+
+func todoPath(id int) string {
+	return fmt.Sprintf("/todos/%d", id)
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// pagedTodos serves GET /v1/todos from n todos, honouring limit and offset,
+// and records the offsets requested.
+func pagedTodos(t *testing.T, n int, offsets *[]int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/todos", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("done"); got != "false" {
+			t.Errorf("done = %q, want false", got)
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		*offsets = append(*offsets, offset)
+		todos := []models.Todo{}
+		for id := offset + 1; id <= min(offset+limit, n); id++ {
+			todos = append(todos, models.Todo{ID: id})
+		}
+		writeJSON(w, http.StatusOK, todos)
+	})
+	return mux
+}
+
+func TestTodosPages(t *testing.T) {
+	var offsets []int
+	c := newTestClient(t, pagedTodos(t, 7, &offsets))
+	done := false
+	var ids []int
+	for todo, err := range c.Todos(context.Background(), ListOptions{Filter: Filter{Done: &done}, PageSize: 3}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, todo.ID)
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if want := []int{0, 3, 6}; !slices.Equal(offsets, want) {
+		t.Errorf("offsets = %v, want %v", offsets, want)
+	}
+}
+
+func TestTodosFullLastPage(t *testing.T) {
+	var offsets []int
+	c := newTestClient(t, pagedTodos(t, 6, &offsets))
+	done := false
+	count := 0
+	for _, err := range c.Todos(context.Background(), ListOptions{Filter: Filter{Done: &done}, PageSize: 3}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+	if count != 6 {
+		t.Errorf("todos = %d, want 6", count)
+	}
+	if len(offsets) != 3 {
+		t.Errorf("offsets = %v, want an empty third page", offsets)
+	}
+}
+
+func TestTodosStopEarly(t *testing.T) {
+	var offsets []int
+	c := newTestClient(t, pagedTodos(t, 100, &offsets))
+	done := false
+	for todo := range c.Todos(context.Background(), ListOptions{Filter: Filter{Done: &done}, PageSize: 10}) {
+		if todo.ID == 5 {
+			break
+		}
+	}
+	if len(offsets) != 1 {
+		t.Errorf("pages fetched = %d, want 1", len(offsets))
+	}
+}
+
+func TestTodosError(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"title": "Forbidden"})
+	}))
+	var errs []error
+	for _, err := range c.Todos(context.Background(), ListOptions{}) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrForbidden) {
+		t.Errorf("errors = %v, want one ErrForbidden", errs)
+	}
+}
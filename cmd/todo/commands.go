@@ -0,0 +1,348 @@
+package main
+
+import (
+	"ToDoProject/client"
+	models "ToDoProject/models"
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func (a *app) loginCommand() *cobra.Command {
+	var username string
+	var passwordStdin bool
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in and store the credentials",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.connect(); err != nil {
+				return err
+			}
+			if username == "" {
+				username = a.cfg.Username
+			}
+			if username == "" {
+				var err error
+				if username, err = prompt("Username: ", false); err != nil {
+					return err
+				}
+			}
+			password, err := prompt("Password: ", !passwordStdin)
+			if err != nil {
+				return err
+			}
+
+			if _, err := a.client.Login(cmd.Context(), username, password); err != nil {
+				return err
+			}
+			a.cfg.Username = username
+			if err := a.cfg.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Logged in to %s as %s\n", a.cfg.Server, username)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&username, "username", "u", "", "username (default: the last one used)")
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from stdin")
+	return cmd
+}
+
+func (a *app) logoutCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Forget the stored credentials",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.connect(); err != nil {
+				return err
+			}
+			a.cfg.AccessToken, a.cfg.RefreshToken, a.cfg.Undo = "", "", nil
+			return a.cfg.save()
+		},
+	}
+}
+
+func (a *app) addCommand() *cobra.Command {
+	var description string
+	cmd := &cobra.Command{
+		Use:   "add TITLE...",
+		Short: "Add a todo",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.requireLogin(); err != nil {
+				return err
+			}
+			todo, err := a.client.CreateTodo(cmd.Context(), models.TodoHandlerRequest{
+				Title:       strings.Join(args, " "),
+				Description: description,
+			})
+			if err != nil {
+				return err
+			}
+			a.cfg.pushUndo(undoEntry{Op: opAdd, After: todo})
+			if err := a.cfg.save(); err != nil {
+				return err
+			}
+			return printTodo(cmd.OutOrStdout(), a.output, todo)
+		},
+	}
+	cmd.Flags().StringVarP(&description, "description", "d", "", "description")
+	return cmd
+}
+
+func (a *app) lsCommand() *cobra.Command {
+	var done, pending bool
+	var before, after string
+	var limit int
+	var filter client.Filter
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List todos",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.requireLogin(); err != nil {
+				return err
+			}
+			switch {
+			case done && pending:
+				return fmt.Errorf("--done and --pending exclude each other")
+			case done, pending:
+				filter.Done = &done
+			}
+			var err error
+			if filter.CreatedBefore, err = parseDate(before); err != nil {
+				return fmt.Errorf("--before: %w", err)
+			}
+			if filter.CreatedAfter, err = parseDate(after); err != nil {
+				return fmt.Errorf("--after: %w", err)
+			}
+
+			var todos []models.Todo
+			for todo, err := range a.client.Todos(cmd.Context(), client.ListOptions{Filter: filter}) {
+				if err != nil {
+					return err
+				}
+				todos = append(todos, todo)
+				if limit > 0 && len(todos) == limit {
+					break
+				}
+			}
+			return printTodos(cmd.OutOrStdout(), a.output, todos)
+		},
+	}
+	cmd.Flags().BoolVar(&done, "done", false, "only done todos")
+	cmd.Flags().BoolVar(&pending, "pending", false, "only pending todos")
+	cmd.Flags().StringVar(&filter.Title, "title", "", "only todos whose title contains this")
+	cmd.Flags().StringVar(&filter.Description, "description", "", "only todos whose description contains this")
+	cmd.Flags().StringVar(&before, "before", "", "only todos created before this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().StringVar(&after, "after", "", "only todos created after this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 0, "show at most this many todos")
+	return cmd
+}
+
+func (a *app) doneCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "done ID...",
+		Short:             "Mark todos as done",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: a.completeIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			done := true
+			return a.updateEach(cmd, args, models.TodoUpdateHandlerRequest{Done: &done})
+		},
+	}
+}
+
+func (a *app) editCommand() *cobra.Command {
+	var title, description string
+	var done bool
+	cmd := &cobra.Command{
+		Use:               "edit ID",
+		Short:             "Change the title, description or state of a todo",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: a.completeIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var req models.TodoUpdateHandlerRequest
+			if cmd.Flags().Changed("title") {
+				req.Title = &title
+			}
+			if cmd.Flags().Changed("description") {
+				req.Description = &description
+			}
+			if cmd.Flags().Changed("done") {
+				req.Done = &done
+			}
+			if req.Title == nil && req.Description == nil && req.Done == nil {
+				return fmt.Errorf("nothing to change; use --title, --description or --done")
+			}
+			return a.updateEach(cmd, args, req)
+		},
+	}
+	cmd.Flags().StringVarP(&title, "title", "t", "", "new title")
+	cmd.Flags().StringVarP(&description, "description", "d", "", "new description")
+	cmd.Flags().BoolVar(&done, "done", false, "mark done (--done=false reopens the todo)")
+	return cmd
+}
+
+// updateEach applies req to the todos named by args, recording each change
+// for undo.
+func (a *app) updateEach(cmd *cobra.Command, args []string, req models.TodoUpdateHandlerRequest) error {
+	if err := a.requireLogin(); err != nil {
+		return err
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	var updated []models.Todo
+	for _, id := range ids {
+		before, err := a.client.GetTodo(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		after, err := a.client.UpdateTodo(cmd.Context(), id, req)
+		if err != nil {
+			return err
+		}
+		a.cfg.pushUndo(undoEntry{Op: opUpdate, Before: before, After: after})
+		if err := a.cfg.save(); err != nil {
+			return err
+		}
+		updated = append(updated, after)
+	}
+	return printTodos(cmd.OutOrStdout(), a.output, updated)
+}
+
+func (a *app) rmCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rm ID...",
+		Short:             "Delete todos",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: a.completeIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.requireLogin(); err != nil {
+				return err
+			}
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			var removed []models.Todo
+			for _, id := range ids {
+				todo, err := a.client.DeleteTodo(cmd.Context(), id)
+				if err != nil {
+					return err
+				}
+				a.cfg.pushUndo(undoEntry{Op: opRemove, Before: todo})
+				if err := a.cfg.save(); err != nil {
+					return err
+				}
+				removed = append(removed, todo)
+			}
+			return printTodos(cmd.OutOrStdout(), a.output, removed)
+		},
+	}
+}
+
+func (a *app) undoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "undo",
+		Short: "Revert the last add, done, edit or rm",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := a.requireLogin(); err != nil {
+				return err
+			}
+			entry, ok := a.cfg.popUndo()
+			if !ok {
+				return fmt.Errorf("nothing to undo")
+			}
+			todo, err := revert(cmd.Context(), a.client, entry)
+			if err != nil {
+				return err
+			}
+			if err := a.cfg.save(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.ErrOrStderr(), "Undo:", describeUndo(entry))
+			return printTodo(cmd.OutOrStdout(), a.output, todo)
+		},
+	}
+}
+
+// completeIDs completes todo ids, showing titles as descriptions.
+func (a *app) completeIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := a.requireLogin(); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for todo, err := range a.client.Todos(cmd.Context(), client.ListOptions{}) {
+		if err != nil {
+			break
+		}
+		id := strconv.Itoa(todo.ID)
+		if strings.HasPrefix(id, toComplete) && !contains(args, id) {
+			completions = append(completions, id+"\t"+todo.Title)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func parseIDs(args []string) ([]int, error) {
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid todo id %q", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// prompt reads a line from stdin, without echo when hidden and stdin is a
+// terminal.
+func prompt(label string, hidden bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, label)
+		if hidden {
+			b, err := term.ReadPassword(fd)
+			fmt.Fprintln(os.Stderr)
+			return string(b), err
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading %s%w", strings.ToLower(label), err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
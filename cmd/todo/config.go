@@ -0,0 +1,74 @@
+package main
+
+import (
+	"ToDoProject/client"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultServer = "http://localhost:8080/v1"
+
+// config is the CLI's state on disk: the server and the credentials of the
+// last login, plus the undo journal.
+type config struct {
+	Server       string      `json:"server"`
+	Username     string      `json:"username,omitempty"`
+	AccessToken  string      `json:"access_token,omitempty"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	Undo         []undoEntry `json:"undo,omitempty"`
+
+	path string
+}
+
+// defaultConfigPath is todo/config.json in the user's configuration
+// directory, e.g. ~/.config/todo/config.json on Linux.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".todo.json"
+	}
+	return filepath.Join(dir, "todo", "config.json")
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{Server: defaultServer, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// save writes the config readable by the user only, as it holds tokens.
+func (c *config) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *config) tokens() client.Tokens {
+	return client.Tokens{AccessToken: c.AccessToken, RefreshToken: c.RefreshToken}
+}
+
+func (c *config) setTokens(t client.Tokens) {
+	c.AccessToken, c.RefreshToken = t.AccessToken, t.RefreshToken
+}
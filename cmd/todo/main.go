@@ -0,0 +1,118 @@
+// Command todo manages todos from the terminal through the HTTP API.
+//
+//	todo login -u alice
+//	todo add "Buy milk" -d "2 litres"
+//	todo ls --pending
+//	todo done 12
+//	todo undo
+//
+// The server URL and credentials are kept in a config file, by default
+// todo/config.json in the user's configuration directory. Run
+// "todo completion --help" for shell completion.
+package main
+
+import (
+	"ToDoProject/client"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// app holds what the subcommands share.
+type app struct {
+	configPath string
+	server     string
+	output     string
+
+	cfg    *config
+	client *client.Client
+}
+
+// connect loads the config and creates the API client. The server flag and
+// the TODO_SERVER environment variable override the configured server.
+func (a *app) connect() error {
+	if a.client != nil {
+		return nil
+	}
+	cfg, err := loadConfig(a.configPath)
+	if err != nil {
+		return err
+	}
+	if a.server == "" {
+		a.server = os.Getenv("TODO_SERVER")
+	}
+	if a.server != "" && a.server != cfg.Server {
+		cfg.Server = a.server
+		cfg.AccessToken, cfg.RefreshToken, cfg.Undo = "", "", nil
+	}
+
+	c, err := client.New(cfg.Server,
+		client.WithTokens(cfg.tokens()),
+		client.WithTokenHook(func(t client.Tokens) {
+			cfg.setTokens(t)
+			if err := cfg.save(); err != nil {
+				fmt.Fprintf(os.Stderr, "todo: could not save credentials: %v\n", err)
+			}
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	a.cfg, a.client = cfg, c
+	return nil
+}
+
+// requireLogin fails early with a hint when there are no credentials.
+func (a *app) requireLogin() error {
+	if err := a.connect(); err != nil {
+		return err
+	}
+	if a.cfg.AccessToken == "" {
+		return errors.New("not logged in; run \"todo login\" first")
+	}
+	return nil
+}
+
+func newRootCommand() *cobra.Command {
+	a := &app{}
+	root := &cobra.Command{
+		Use:           "todo",
+		Short:         "Manage your todos from the terminal",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVar(&a.configPath, "config", defaultConfigPath(), "config file")
+	root.PersistentFlags().StringVar(&a.server, "server", "", "API base URL including the version, e.g. "+defaultServer+" (default from config or $TODO_SERVER)")
+	root.PersistentFlags().StringVarP(&a.output, "output", "o", outputTable, "output format: "+strings.Join(outputFormats, ", "))
+	root.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+
+	root.AddCommand(
+		a.loginCommand(),
+		a.logoutCommand(),
+		a.addCommand(),
+		a.lsCommand(),
+		a.doneCommand(),
+		a.editCommand(),
+		a.rmCommand(),
+		a.undoCommand(),
+	)
+	return root
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		if errors.Is(err, client.ErrUnauthorized) {
+			err = fmt.Errorf("%w; run \"todo login\" again", err)
+		}
+		fmt.Fprintln(os.Stderr, "todo:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	models "ToDoProject/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputPlain = "plain"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputPlain}
+
+// printTodos writes todos in the given format. plain prints one todo per
+// line for use with grep and cut: id, done state and title separated by
+// tabs.
+func printTodos(w io.Writer, format string, todos []models.Todo) error {
+	switch format {
+	case outputJSON:
+		if todos == nil {
+			todos = []models.Todo{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(todos)
+	case outputPlain:
+		for _, t := range todos {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", t.ID, doneMark(t.Done), t.Title)
+		}
+		return nil
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tDONE\tTITLE\tDESCRIPTION\tCREATED")
+		for _, t := range todos {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n",
+				t.ID, doneMark(t.Done), t.Title, oneLine(t.Description), t.CreatedAt.Local().Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %q, want one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+func printTodo(w io.Writer, format string, todo models.Todo) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(todo)
+	}
+	return printTodos(w, format, []models.Todo{todo})
+}
+
+func doneMark(done bool) string {
+	if done {
+		return "x"
+	}
+	return " "
+}
+
+func oneLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) > 40 {
+		s = string([]rune(s)[:39]) + "…"
+	}
+	return s
+}
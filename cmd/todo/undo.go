@@ -0,0 +1,91 @@
+package main
+
+import (
+	"ToDoProject/client"
+	models "ToDoProject/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Operations recorded in the undo journal.
+const (
+	opAdd    = "add"
+	opUpdate = "update"
+	opRemove = "rm"
+)
+
+const maxUndo = 20
+
+// undoEntry records one change made through the CLI. Before is the todo
+// before the change (unset for add), After the todo after it (unset for rm).
+type undoEntry struct {
+	Op     string      `json:"op"`
+	Before models.Todo `json:"before"`
+	After  models.Todo `json:"after"`
+}
+
+func (c *config) pushUndo(e undoEntry) {
+	c.Undo = append(c.Undo, e)
+	if len(c.Undo) > maxUndo {
+		c.Undo = c.Undo[len(c.Undo)-maxUndo:]
+	}
+}
+
+func (c *config) popUndo() (undoEntry, bool) {
+	if len(c.Undo) == 0 {
+		return undoEntry{}, false
+	}
+	e := c.Undo[len(c.Undo)-1]
+	c.Undo = c.Undo[:len(c.Undo)-1]
+	return e, true
+}
+
+// revert undoes e. Updates and additions are only reverted while the todo
+// is unchanged since; removed todos are recreated under a new id.
+func revert(ctx context.Context, c *client.Client, e undoEntry) (models.Todo, error) {
+	switch e.Op {
+	case opAdd:
+		current, err := c.GetTodo(ctx, e.After.ID)
+		if err != nil {
+			return models.Todo{}, err
+		}
+		if current.Version != e.After.Version {
+			return models.Todo{}, fmt.Errorf("todo %d was changed after it was added; not removing it", e.After.ID)
+		}
+		return c.DeleteTodo(ctx, e.After.ID)
+
+	case opUpdate:
+		todo, err := c.JSONPatchTodo(ctx, e.After.ID, []client.PatchOperation{
+			{Op: "test", Path: "/version", Value: e.After.Version},
+			{Op: "replace", Path: "/title", Value: e.Before.Title},
+			{Op: "replace", Path: "/description", Value: e.Before.Description},
+			{Op: "replace", Path: "/done", Value: e.Before.Done},
+		})
+		if errors.Is(err, client.ErrConflict) {
+			return models.Todo{}, fmt.Errorf("todo %d was changed again since; not reverting it", e.After.ID)
+		}
+		return todo, err
+
+	case opRemove:
+		todo, err := c.CreateTodo(ctx, models.TodoHandlerRequest{Title: e.Before.Title, Description: e.Before.Description})
+		if err != nil || !e.Before.Done {
+			return todo, err
+		}
+		done := true
+		return c.UpdateTodo(ctx, todo.ID, models.TodoUpdateHandlerRequest{Done: &done})
+	}
+	return models.Todo{}, fmt.Errorf("unknown undo operation %q", e.Op)
+}
+
+func describeUndo(e undoEntry) string {
+	switch e.Op {
+	case opAdd:
+		return fmt.Sprintf("removed todo %d %q", e.After.ID, e.After.Title)
+	case opUpdate:
+		return fmt.Sprintf("restored todo %d %q", e.After.ID, e.Before.Title)
+	case opRemove:
+		return fmt.Sprintf("recreated todo %q", e.Before.Title)
+	}
+	return e.Op
+}
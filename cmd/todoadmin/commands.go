@@ -0,0 +1,398 @@
+package main
+
+import (
+	"ToDoProject/auth"
+	models "ToDoProject/models"
+	"ToDoProject/validate"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func (a *admin) migrateCommand() *cobra.Command {
+	var status bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending schema migrations",
+		Long: "Apply pending schema migrations in one transaction. The first migration\n" +
+			"creates the original schema only where it is missing, so existing databases\n" +
+			"can be migrated as they are.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			if status {
+				migrations, err := a.store.Migrations(cmd.Context())
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "MIGRATION\tAPPLIED")
+				for _, m := range migrations {
+					applied := "pending"
+					if !m.AppliedAt.IsZero() {
+						applied = m.AppliedAt.Format(time.DateTime)
+					}
+					fmt.Fprintf(tw, "%s\t%s\n", m.Name, applied)
+				}
+				return tw.Flush()
+			}
+
+			applied, err := a.store.Migrate(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				fmt.Fprintln(out, "Schema is up to date")
+			}
+			for _, name := range applied {
+				fmt.Fprintln(out, "Applied", name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&status, "status", false, "list migrations and whether they are applied instead")
+	return cmd
+}
+
+func (a *admin) userCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users",
+	}
+
+	var passwordStdin bool
+	create := &cobra.Command{
+		Use:   "create USERNAME",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := newPassword(passwordStdin)
+			if err != nil {
+				return err
+			}
+			req := models.RegisterRequest{Username: args[0], Password: password}
+			if err := checkRequest(&req); err != nil {
+				return err
+			}
+			user, err := a.store.CreateUser(cmd.Context(), req.Username, req.Password)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created user %s with id %d\n", user.Username, user.ID)
+			return nil
+		},
+	}
+	create.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from stdin")
+
+	resetPassword := &cobra.Command{
+		Use:   "reset-password USERNAME",
+		Short: "Set a new password for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := a.store.FindUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			password, err := newPassword(passwordStdin)
+			if err != nil {
+				return err
+			}
+			req := models.RegisterRequest{Username: user.Username, Password: password}
+			if err := checkRequest(&req); err != nil {
+				return err
+			}
+			if err := a.store.ResetPassword(cmd.Context(), user.ID, req.Password); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Password of %s reset\n", user.Username)
+			return nil
+		},
+	}
+	resetPassword.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from stdin")
+
+	unlock := &cobra.Command{
+		Use:   "unlock USERNAME",
+		Short: "Lift the lockout of a user after failed logins",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := a.store.FindUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if err := a.store.UnlockUser(cmd.Context(), user.ID); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "User %s unlocked\n", user.Username)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		create,
+		a.setDisabledCommand("disable", "Stop a user from logging in or refreshing tokens", true),
+		a.setDisabledCommand("enable", "Allow a disabled user to log in again", false),
+		resetPassword,
+		unlock,
+		a.setRoleCommand("grant", "Give a user a role", true),
+		a.setRoleCommand("revoke", "Take a role away from a user", false),
+	)
+	return cmd
+}
+
+func (a *admin) setRoleCommand(name, short string, granted bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " USERNAME ROLE",
+		Short: short,
+		Long: short + ". Roles: " + strings.Join(auth.Roles, ", ") + ".\n" +
+			"The change applies at once, to access tokens already issued too.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			role := args[1]
+			if !slices.Contains(auth.Roles, role) {
+				return fmt.Errorf("unknown role %q, want one of %s", role, strings.Join(auth.Roles, ", "))
+			}
+			user, err := a.store.FindUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if err := a.store.SetUserRole(cmd.Context(), user.ID, role, granted); err != nil {
+				return err
+			}
+			if granted {
+				fmt.Fprintf(cmd.OutOrStdout(), "User %s granted role %s\n", user.Username, role)
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "Role %s revoked from user %s\n", role, user.Username)
+			}
+			return nil
+		},
+	}
+}
+
+func (a *admin) setDisabledCommand(name, short string, disabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " USERNAME",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := a.store.FindUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if err := a.store.SetUserDisabled(cmd.Context(), user.ID, disabled); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "User %s %sd\n", user.Username, name)
+			return nil
+		},
+	}
+}
+
+func (a *admin) purgeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete old history",
+		Long: "Delete old history. Sync clients whose cursor predates the purged rows\n" +
+			"miss those changes and have to sync again from scratch.",
+	}
+	cmd.AddCommand(
+		a.purgeSubcommand("history", "Delete history rows older than the cutoff",
+			func(ctx context.Context, before time.Time, dryRun bool) (int64, error) {
+				return a.store.PurgeHistory(ctx, before, dryRun)
+			}),
+		a.purgeSubcommand("trash", "Delete the history of todos deleted before the cutoff",
+			func(ctx context.Context, before time.Time, dryRun bool) (int64, error) {
+				return a.store.PurgeTrash(ctx, before, dryRun)
+			}),
+	)
+	return cmd
+}
+
+func (a *admin) purgeSubcommand(name, short string, purge func(ctx context.Context, before time.Time, dryRun bool) (int64, error)) *cobra.Command {
+	var days int
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--older-than must be a positive number of days")
+			}
+			before := time.Now().AddDate(0, 0, -days)
+			n, err := purge(cmd.Context(), before, dryRun)
+			if err != nil {
+				return err
+			}
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %d history rows from before %s\n", verb, n, before.Format(time.DateTime))
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&days, "older-than", 0, "cutoff age in days (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only count the rows that would be deleted")
+	cmd.MarkFlagRequired("older-than")
+	return cmd
+}
+
+func (a *admin) exportCommand() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "export USERNAME",
+		Short: "Write a user with their todos and history as JSON",
+		Long: "Write a user with their todos and history as JSON. The export contains\n" +
+			"the user's password hash; store it accordingly.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			export, err := a.store.ExportUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if file != "" && file != "-" {
+				f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(export)
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "file to create, - for stdout")
+	return cmd
+}
+
+func (a *admin) importCommand() *cobra.Command {
+	var file, as string
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Create a user from an export",
+		Long: "Create a user from an export. Todos get new ids; the user name must not\n" +
+			"be taken yet.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := cmd.InOrStdin()
+			if file != "" && file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			var export models.UserExport
+			dec := json.NewDecoder(in)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&export); err != nil {
+				return fmt.Errorf("reading export: %w", err)
+			}
+
+			id, err := a.store.ImportUser(cmd.Context(), export, as)
+			if err != nil {
+				return err
+			}
+			username := as
+			if username == "" {
+				username = export.Username
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %s as user %d with %d todos and %d history rows\n",
+				username, id, len(export.Todos), len(export.History))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "export to read, - for stdin")
+	cmd.Flags().StringVar(&as, "as", "", "create the user under this name instead")
+	return cmd
+}
+
+func (a *admin) statsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show row counts and sizes of the tables",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats, err := a.store.Stats(cmd.Context())
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(tw, "TABLE\tROWS\tSIZE\t")
+			for _, t := range stats {
+				fmt.Fprintf(tw, "%s\t%d\t%s\t\n", t.Name, t.Rows, formatBytes(t.Bytes))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+// checkRequest applies the API's validation rules, so users created here
+// could also have registered themselves.
+func checkRequest(req *models.RegisterRequest) error {
+	errs := validate.Struct(req)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Field + " " + e.Message
+	}
+	return fmt.Errorf("invalid user: %s", strings.Join(msgs, "; "))
+}
+
+// newPassword reads a password from the terminal, asking twice, or a single
+// line from stdin.
+func newPassword(fromStdin bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if fromStdin || !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "New password: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return string(first), nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
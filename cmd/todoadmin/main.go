@@ -0,0 +1,82 @@
+// Command todoadmin operates a todo server's database directly, without
+// going through the API.
+//
+//	todoadmin migrate
+//	todoadmin user create alice
+//	todoadmin user disable alice
+//	todoadmin purge history --older-than 365
+//	todoadmin export alice -f alice.json
+//	todoadmin stats
+//
+// It reads the database settings like the server does, from the file given
+// with --config or CONFIG_FILE and the DB_* environment variables.
+package main
+
+import (
+	"ToDoProject/config"
+	"ToDoProject/store"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+)
+
+// admin holds what the subcommands share.
+type admin struct {
+	configPath string
+	store      *store.TodoStore
+}
+
+// open connects to the database on first use.
+func (a *admin) open(cmd *cobra.Command, args []string) error {
+	if a.store != nil {
+		return nil
+	}
+	cfg, err := config.LoadFile(a.configPath)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	s, err := store.NewTodoStore(cfg.DB.ConnString())
+	if err != nil {
+		return fmt.Errorf("connecting to the database: %w", err)
+	}
+	cfg.DB.ConfigurePool(s.DB)
+	a.store = s
+	return nil
+}
+
+func newRootCommand() *cobra.Command {
+	a := &admin{}
+	root := &cobra.Command{
+		Use:           "todoadmin",
+		Short:         "Operate the todo database",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	for _, cmd := range []*cobra.Command{
+		a.migrateCommand(),
+		a.userCommand(),
+		a.purgeCommand(),
+		a.exportCommand(),
+		a.importCommand(),
+		a.statsCommand(),
+	} {
+		// Only the database commands connect, so help and completion
+		// work without one.
+		cmd.PersistentPreRunE = a.open
+		root.AddCommand(cmd)
+	}
+	return root
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "todoadmin:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,457 @@
+// Package config loads the server configuration. Every setting has a
+// default and can be overridden, in increasing order of precedence, by a
+// YAML or TOML file, an environment variable and a command-line flag:
+//
+//	db:
+//	  host: db.internal
+//	  max_open_conns: 40
+//
+//	DB_HOST=db.internal DB_MAX_OPEN_CONNS=40
+//
+//	-db-host db.internal -db-max-open-conns 40
+//
+// File keys are the `key` tags of the nested structs joined with dots, flags
+// are the same path joined with dashes. Settings tagged secret have no flag,
+// so they do not show up in process listings, and are redacted by Summary.
+package config
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+type Config struct {
+	Env string `key:"env" env:"APP_ENV" usage:"development or production; production refuses weak secrets"`
+	// ShutdownTimeout bounds how long draining requests and stopping
+	// workers may take before the rest is cut off.
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"time to drain requests and stop workers on shutdown"`
+	Log             Log           `key:"log"`
+	Tracing         Tracing       `key:"tracing"`
+	HTTP            HTTP          `key:"http"`
+	GRPC            GRPC          `key:"grpc"`
+	DB              DB            `key:"db"`
+	JWT             JWT           `key:"jwt"`
+	API             API           `key:"api"`
+	RateLimit       RateLimit     `key:"rate_limit"`
+	Lockout         Lockout       `key:"lockout"`
+	Outbox          Outbox        `key:"outbox"`
+}
+
+type Log struct {
+	Format string `key:"format" env:"LOG_FORMAT" usage:"log format, text or json"`
+	Level  string `key:"level" env:"LOG_LEVEL" usage:"minimum log level: debug, info, warn or error"`
+}
+
+type HTTP struct {
+	Port              int           `key:"port" env:"PORT" usage:"HTTP port"`
+	ReadHeaderTimeout time.Duration `key:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time to read request headers"`
+	ReadTimeout       time.Duration `key:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time to read a whole request"`
+	WriteTimeout      time.Duration `key:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time to write a response, 0 for none; it also ends event streams"`
+	IdleTimeout       time.Duration `key:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"how long idle keep-alive connections stay open"`
+}
+
+// Tracing selects where spans go. With the otlp exporter the standard
+// OTEL_EXPORTER_OTLP_* variables, e.g. for headers, are honoured as well.
+type Tracing struct {
+	Exporter    string  `key:"exporter" env:"TRACING_EXPORTER" usage:"span exporter: none, stdout or otlp"`
+	Endpoint    string  `key:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP collector URL, e.g. http://localhost:4318"`
+	ServiceName string  `key:"service_name" env:"OTEL_SERVICE_NAME" usage:"service name reported with spans"`
+	SampleRatio float64 `key:"sample_ratio" env:"TRACING_SAMPLE_RATIO" usage:"fraction of new traces to record, 0 to 1; traces started upstream follow the caller's decision"`
+}
+
+type GRPC struct {
+	Port int `key:"port" env:"GRPC_PORT" usage:"gRPC port"`
+}
+
+type DB struct {
+	Host            string        `key:"host" env:"DB_HOST" usage:"PostgreSQL host"`
+	Port            int           `key:"port" env:"DB_PORT" usage:"PostgreSQL port"`
+	User            string        `key:"user" env:"DB_USER" usage:"PostgreSQL user"`
+	Password        string        `key:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string        `key:"name" env:"DB_NAME" usage:"database name"`
+	SSLMode         string        `key:"sslmode" env:"DB_SSLMODE" usage:"disable, require, verify-ca or verify-full"`
+	ConnectTimeout  time.Duration `key:"connect_timeout" env:"DB_CONNECT_TIMEOUT" usage:"time to establish a connection, 0 for none"`
+	MaxOpenConns    int           `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"maximum open connections, 0 for unlimited"`
+	MaxIdleConns    int           `key:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"maximum idle connections"`
+	ConnMaxLifetime time.Duration `key:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"maximum age of a connection, 0 for unlimited"`
+	ConnMaxIdleTime time.Duration `key:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" usage:"maximum idle time of a connection, 0 for unlimited"`
+}
+
+type JWT struct {
+	// Secret signs the tokens. jwt_secret_key is the variable older
+	// versions read; JWT_SECRET_KEY wins when both are set.
+	Secret          string        `key:"secret" env:"JWT_SECRET_KEY,jwt_secret_key" secret:"true"`
+	AccessTokenTTL  time.Duration `key:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL" usage:"access token lifetime"`
+	RefreshTokenTTL time.Duration `key:"refresh_token_ttl" env:"JWT_REFRESH_TOKEN_TTL" usage:"refresh token lifetime"`
+}
+
+type API struct {
+	BulkConfirmThreshold int `key:"bulk_confirm_threshold" env:"BULK_CONFIRM_THRESHOLD" usage:"todos a bulk update or delete may touch before the count must be confirmed"`
+	// LegacySunset is when the unversioned routes stop being served. Zero
+	// means they are deprecated without a sunset date.
+	LegacySunset time.Time `key:"legacy_sunset" env:"LEGACY_API_SUNSET" usage:"sunset date of the unversioned routes, YYYY-MM-DD"`
+}
+
+// RateLimit sets the request allowances of the rate limiter. Each is a
+// token bucket holding Requests tokens that refill over Per, so it allows
+// bursts of up to Requests.
+type RateLimit struct {
+	Backend         string `key:"backend" env:"RATE_LIMIT_BACKEND" usage:"where buckets are kept: memory, postgres to share them between replicas, or none"`
+	AuthPerIP       Rate   `key:"auth_per_ip" env:"RATE_LIMIT_AUTH_PER_IP" usage:"login, register and refresh requests per client IP, e.g. 20/1m, 0 for no limit"`
+	AuthPerUsername Rate   `key:"auth_per_username" env:"RATE_LIMIT_AUTH_PER_USERNAME" usage:"login and register requests per username, 0 for no limit"`
+	APIPerUser      Rate   `key:"api_per_user" env:"RATE_LIMIT_API_PER_USER" usage:"authenticated API requests per user, 0 for no limit"`
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For
+	// entry, which the proxy in front of the server appends. Without a
+	// proxy, clients could pick their own IP that way.
+	TrustForwardedFor bool `key:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR" usage:"take the client IP from X-Forwarded-For; only behind a proxy"`
+}
+
+// Lockout throttles password guessing per account; see store.LockoutPolicy.
+type Lockout struct {
+	Threshold int           `key:"threshold" env:"LOCKOUT_THRESHOLD" usage:"failed logins in a row that lock an account, 0 to never lock"`
+	Delay     time.Duration `key:"delay" env:"LOCKOUT_DELAY" usage:"wait after a failed login, doubled with each further failure"`
+	Duration  time.Duration `key:"duration" env:"LOCKOUT_DURATION" usage:"how long a locked account stays locked"`
+}
+
+// Outbox configures the relay that publishes todo events to consumers.
+type Outbox struct {
+	Retention  time.Duration `key:"retention" env:"OUTBOX_RETENTION" usage:"how long delivered events are kept for clients resuming streams by event id"`
+	WebhookURL string        `key:"webhook_url" env:"OUTBOX_WEBHOOK_URL" usage:"URL every todo event is POSTed to, empty for none"`
+	// WebhookSecret signs webhook bodies so receivers can tell they come
+	// from this server.
+	WebhookSecret string `key:"webhook_secret" env:"OUTBOX_WEBHOOK_SECRET" secret:"true"`
+}
+
+// Rate is a number of requests per period, written like 10/1m. The zero
+// Rate is written 0 and means no limit.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	v := string(text)
+	if v == "0" || v == "" {
+		*r = Rate{}
+		return nil
+	}
+	n, per, ok := strings.Cut(v, "/")
+	requests, err := strconv.Atoi(n)
+	if !ok || err != nil || requests <= 0 {
+		return fmt.Errorf("invalid rate %q, use e.g. 10/1m or 0", v)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid rate %q, use e.g. 10/1m or 0", v)
+	}
+	*r = Rate{Requests: requests, Per: d}
+	return nil
+}
+
+func (r Rate) String() string {
+	if r.Requests == 0 {
+		return "0"
+	}
+	// 1m rather than 1m0s.
+	per := r.Per.String()
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+	return fmt.Sprintf("%d/%s", r.Requests, per)
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		Env:             EnvDevelopment,
+		ShutdownTimeout: 30 * time.Second,
+		Log:             Log{Format: "text", Level: "info"},
+		Tracing:         Tracing{Exporter: "none", ServiceName: "todo-api", SampleRatio: 1},
+		HTTP: HTTP{
+			Port:              8080,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		},
+		GRPC: GRPC{Port: 9090},
+		DB: DB{
+			Host:            "127.0.0.1",
+			Port:            5432,
+			User:            "postgres",
+			Name:            "todo",
+			SSLMode:         "disable",
+			ConnectTimeout:  5 * time.Second,
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		JWT: JWT{
+			AccessTokenTTL:  24 * time.Hour,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+		},
+		API: API{BulkConfirmThreshold: 50},
+		RateLimit: RateLimit{
+			Backend:         "memory",
+			AuthPerIP:       Rate{Requests: 20, Per: time.Minute},
+			AuthPerUsername: Rate{Requests: 5, Per: time.Minute},
+			APIPerUser:      Rate{Requests: 300, Per: time.Minute},
+		},
+		Lockout: Lockout{Threshold: 10, Delay: time.Second, Duration: 15 * time.Minute},
+		Outbox:  Outbox{Retention: 7 * 24 * time.Hour},
+	}
+}
+
+// Load parses args as flags and builds the configuration from the defaults,
+// the file named by -config or CONFIG_FILE, the environment and the flags.
+// It returns flag.ErrHelp after printing usage for -h.
+func Load(name string, args []string) (*Config, error) {
+	settings := settingsOf(Default())
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		usage := s.usage
+		if len(s.env) > 0 {
+			usage += " (env " + s.env[0] + ")"
+		}
+		fs.Func(s.flag, usage+" (default "+s.String()+")", func(v string) error {
+			if err := s.set(v); err != nil {
+				return err
+			}
+			flags[s.key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	cfg := Default()
+	if err := cfg.load(*file, flags); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFile builds the configuration from the defaults, the file at path,
+// which may be empty, and the environment. It is meant for tools that have
+// flags of their own.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+	if err := cfg.load(path, nil); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) load(path string, flags map[string]string) error {
+	settings := settingsOf(c)
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		if err := apply(settings, values, path); err != nil {
+			return err
+		}
+	}
+	if err := apply(settings, envValues(settings), "environment"); err != nil {
+		return err
+	}
+	if err := apply(settings, flags, "flags"); err != nil {
+		return err
+	}
+	return c.validate()
+}
+
+// validate checks the configuration as a whole and reports every problem.
+// Outside of production a missing JWT secret is replaced by a random one,
+// which invalidates all tokens on restart.
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Env == EnvDevelopment || c.Env == EnvProduction, "env: must be %q or %q, not %q", EnvDevelopment, EnvProduction, c.Env)
+	check(c.ShutdownTimeout > 0, "shutdown_timeout: must be positive")
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: must be text or json, not %q", c.Log.Format)
+	check(validLevel(c.Log.Level), "log.level: must be debug, info, warn or error, not %q", c.Log.Level)
+	check(c.Tracing.Exporter == "none" || c.Tracing.Exporter == "stdout" || c.Tracing.Exporter == "otlp",
+		"tracing.exporter: must be none, stdout or otlp, not %q", c.Tracing.Exporter)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.service_name: must be set")
+	check(validPort(c.HTTP.Port), "http.port: %d is not a valid port", c.HTTP.Port)
+	check(validPort(c.GRPC.Port), "grpc.port: %d is not a valid port", c.GRPC.Port)
+	check(c.HTTP.Port != c.GRPC.Port, "grpc.port: must differ from http.port")
+	check(c.HTTP.ReadHeaderTimeout >= 0 && c.HTTP.ReadTimeout >= 0 && c.HTTP.WriteTimeout >= 0 && c.HTTP.IdleTimeout >= 0,
+		"http: timeouts must not be negative")
+
+	check(c.DB.Host != "", "db.host: must be set")
+	check(validPort(c.DB.Port), "db.port: %d is not a valid port", c.DB.Port)
+	check(c.DB.User != "", "db.user: must be set")
+	check(c.DB.Name != "", "db.name: must be set")
+	check(validSSLMode(c.DB.SSLMode), "db.sslmode: %q is not a valid mode", c.DB.SSLMode)
+	check(c.DB.ConnectTimeout >= 0 && c.DB.ConnMaxLifetime >= 0 && c.DB.ConnMaxIdleTime >= 0,
+		"db: timeouts must not be negative")
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns: must not be negative")
+	check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns: must not be negative")
+	check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "db.max_idle_conns: must not exceed db.max_open_conns")
+
+	check(c.JWT.AccessTokenTTL > 0, "jwt.access_token_ttl: must be positive")
+	check(c.JWT.RefreshTokenTTL >= c.JWT.AccessTokenTTL, "jwt.refresh_token_ttl: must not be shorter than jwt.access_token_ttl")
+	if problem := weakSecret(c.JWT.Secret); problem != "" {
+		switch {
+		case c.Env == EnvProduction:
+			errs = append(errs, fmt.Errorf("jwt.secret: %s; set JWT_SECRET_KEY to at least %d random bytes", problem, minSecretLen))
+		case c.JWT.Secret == "":
+			log.Printf("WARNING: JWT_SECRET_KEY is not set; using a random secret, tokens will not survive a restart")
+			c.JWT.Secret = randomSecret()
+		default:
+			log.Printf("WARNING: jwt.secret: %s; this is refused in production", problem)
+		}
+	}
+
+	check(c.API.BulkConfirmThreshold >= 0, "api.bulk_confirm_threshold: must not be negative")
+	check(c.RateLimit.Backend == "none" || c.RateLimit.Backend == "memory" || c.RateLimit.Backend == "postgres",
+		"rate_limit.backend: must be none, memory or postgres, not %q", c.RateLimit.Backend)
+	check(c.Lockout.Threshold >= 0, "lockout.threshold: must not be negative")
+	check(c.Lockout.Delay >= 0 && c.Lockout.Duration >= 0, "lockout: durations must not be negative")
+	check(c.Lockout.Delay <= c.Lockout.Duration, "lockout.delay: must not exceed lockout.duration")
+	check(c.Outbox.Retention >= 0, "outbox.retention: must not be negative")
+	if c.Outbox.WebhookURL != "" {
+		u, err := url.Parse(c.Outbox.WebhookURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "outbox.webhook_url: %q is not an http or https URL", c.Outbox.WebhookURL)
+		check(c.Outbox.WebhookSecret != "", "outbox.webhook_secret: must be set with outbox.webhook_url")
+	}
+	return errors.Join(errs...)
+}
+
+const minSecretLen = 32
+
+// weakSecrets are placeholders from documentation and examples.
+var weakSecrets = []string{"none", "secret", "changeme", "change-me", "your_secret_key", "jwt_secret", "password"}
+
+// weakSecret describes why secret is unfit to sign tokens, or returns "".
+func weakSecret(secret string) string {
+	switch {
+	case secret == "":
+		return "not set"
+	case len(secret) < minSecretLen:
+		return fmt.Sprintf("shorter than %d bytes", minSecretLen)
+	}
+	for _, weak := range weakSecrets {
+		if strings.Contains(strings.ToLower(secret), weak) {
+			return "contains the placeholder " + weak
+		}
+	}
+	if strings.Count(secret, secret[:1]) == len(secret) {
+		return "repeats a single character"
+	}
+	return ""
+}
+
+func randomSecret() string {
+	b := make([]byte, minSecretLen)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validPort(p int) bool {
+	return p > 0 && p < 65536
+}
+
+func validLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+func validSSLMode(mode string) bool {
+	switch mode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		return true
+	}
+	return false
+}
+
+// Summary lists every setting by file key with its value, secrets
+// redacted, for diagnostics.
+func (c *Config) Summary() map[string]string {
+	summary := make(map[string]string)
+	for _, s := range settingsOf(c) {
+		switch {
+		case !s.secret:
+			summary[s.key] = s.String()
+		case s.field.String() == "":
+			summary[s.key] = "(not set)"
+		default:
+			summary[s.key] = "(redacted)"
+		}
+	}
+	return summary
+}
+
+// ConnString returns the lib/pq connection string.
+func (c DB) ConnString() string {
+	params := []string{
+		"host=" + quoteConnValue(c.Host),
+		fmt.Sprintf("port=%d", c.Port),
+		"user=" + quoteConnValue(c.User),
+		"password=" + quoteConnValue(c.Password),
+		"dbname=" + quoteConnValue(c.Name),
+		"sslmode=" + quoteConnValue(c.SSLMode),
+	}
+	if c.ConnectTimeout > 0 {
+		// connect_timeout is in whole seconds; round up so a short timeout
+		// does not become none.
+		params = append(params, fmt.Sprintf("connect_timeout=%d", int((c.ConnectTimeout+time.Second-1)/time.Second)))
+	}
+	return strings.Join(params, " ")
+}
+
+func quoteConnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// ConfigurePool applies the connection pool settings to db.
+func (c DB) ConfigurePool(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
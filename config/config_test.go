@@ -0,0 +1,225 @@
+package config
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const strongSecret = "8f3a1c5e7b9d2f4a6c8e0b1d3f5a7c9e2b4d6f8a"
+
+// cleanEnv unsets every variable Load reads, for the duration of the test.
+// envValues treats empty variables as unset.
+func cleanEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settingsOf(Default()) {
+		for _, name := range s.env {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+// quietLog drops the warnings validate logs, for the duration of the test.
+func quietLog(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := "db:\n  host: file.internal\n  max_open_conns: 40\n"
+	tests := []struct {
+		name      string
+		file      bool
+		env       map[string]string
+		args      []string
+		wantHost  string
+		wantConns int
+	}{
+		{name: "defaults", wantHost: "127.0.0.1", wantConns: 20},
+		{name: "file", file: true, wantHost: "file.internal", wantConns: 40},
+		{
+			name:      "environment over file",
+			file:      true,
+			env:       map[string]string{"DB_HOST": "env.internal"},
+			wantHost:  "env.internal",
+			wantConns: 40,
+		},
+		{
+			name:      "flags over environment",
+			file:      true,
+			env:       map[string]string{"DB_HOST": "env.internal", "DB_MAX_OPEN_CONNS": "30"},
+			args:      []string{"-db-host", "flag.internal"},
+			wantHost:  "flag.internal",
+			wantConns: 30,
+		},
+		{
+			name:      "flags over file",
+			file:      true,
+			args:      []string{"-db-max-open-conns", "50"},
+			wantHost:  "file.internal",
+			wantConns: 50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanEnv(t)
+			quietLog(t)
+			for name, v := range tt.env {
+				t.Setenv(name, v)
+			}
+			args := tt.args
+			if tt.file {
+				args = append([]string{"-config", writeFile(t, "config.yaml", file)}, args...)
+			}
+
+			cfg, err := Load("test", args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DB.Host != tt.wantHost {
+				t.Errorf("db.host = %q, want %q", cfg.DB.Host, tt.wantHost)
+			}
+			if cfg.DB.MaxOpenConns != tt.wantConns {
+				t.Errorf("db.max_open_conns = %d, want %d", cfg.DB.MaxOpenConns, tt.wantConns)
+			}
+		})
+	}
+}
+
+func TestLoadFileFromEnvironment(t *testing.T) {
+	cleanEnv(t)
+	quietLog(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.toml", "[http]\nport = 8081\n"))
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Port != 8081 {
+		t.Errorf("http.port = %d, want 8081 from the TOML file", cfg.HTTP.Port)
+	}
+}
+
+func TestLoadLegacySecretVariable(t *testing.T) {
+	cleanEnv(t)
+	t.Setenv("jwt_secret_key", strongSecret+"-legacy")
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWT.Secret != strongSecret+"-legacy" {
+		t.Errorf("jwt.secret = %q, want the legacy variable's", cfg.JWT.Secret)
+	}
+
+	t.Setenv("JWT_SECRET_KEY", strongSecret)
+	cfg, err = Load("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWT.Secret != strongSecret {
+		t.Errorf("jwt.secret = %q, want JWT_SECRET_KEY's", cfg.JWT.Secret)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		want string
+	}{
+		{name: "unknown file key", file: "db:\n  hots: x\n", want: `unknown setting "db.hots"`},
+		{name: "invalid file value", file: "http:\n  port: eighty\n", want: "http.port"},
+		{name: "flag for a secret", args: []string{"-jwt-secret", strongSecret}, want: "-jwt-secret"},
+		{name: "invalid flag value", args: []string{"-rate-limit-api-per-user", "lots"}, want: "invalid rate"},
+		{name: "invalid combination", args: []string{"-grpc-port", "8080"}, want: "grpc.port: must differ from http.port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanEnv(t)
+			quietLog(t)
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "config.yaml", tt.file)}, args...)
+			}
+			_, err := Load("test", args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductionRefusesWeakSecrets(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"unset", "", "not set"},
+		{"short", "s3cr3t-but-short", "shorter than 32 bytes"},
+		{"placeholder", "changeme-changeme-changeme-changeme", "contains the placeholder changeme"},
+		{"placeholder in other case", "ChangeMe-0123456789abcdefghijklmnop", "contains the placeholder changeme"},
+		{"one character repeated", strings.Repeat("x", 40), "repeats a single character"},
+		{"strong", strongSecret, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanEnv(t)
+			quietLog(t)
+			t.Setenv("APP_ENV", EnvProduction)
+			t.Setenv("JWT_SECRET_KEY", tt.secret)
+
+			cfg, err := Load("test", nil)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Load = %v, want the secret accepted", err)
+				}
+				if cfg.JWT.Secret != tt.secret {
+					t.Errorf("jwt.secret = %q, want %q", cfg.JWT.Secret, tt.secret)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "jwt.secret: "+tt.want) {
+				t.Errorf("Load = %v, want jwt.secret refused as %q", err, tt.want)
+			}
+
+			// Development only warns, and makes up a secret when none is set.
+			t.Setenv("APP_ENV", EnvDevelopment)
+			cfg, err = Load("test", nil)
+			if err != nil {
+				t.Fatalf("development: Load = %v, want a warning only", err)
+			}
+			if tt.secret == "" && weakSecret(cfg.JWT.Secret) != "" {
+				t.Errorf("development: made-up secret %q is weak", cfg.JWT.Secret)
+			}
+			if tt.secret != "" && cfg.JWT.Secret != tt.secret {
+				t.Errorf("development: jwt.secret = %q, want %q", cfg.JWT.Secret, tt.secret)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	cleanEnv(t)
+	quietLog(t)
+	t.Setenv("LOG_LEVEL", "debug")
+	cfg, err := LoadFile(writeFile(t, "config.yml", "log:\n  level: warn\n  format: json\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.Level != "debug" || cfg.Log.Format != "json" {
+		t.Errorf("log = %+v, want level from the environment and format from the file", cfg.Log)
+	}
+}
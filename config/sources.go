@@ -0,0 +1,222 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is one leaf field of a Config, addressable by file key,
+// environment variable and flag.
+type setting struct {
+	key    string
+	env    []string
+	flag   string
+	usage  string
+	secret bool
+	field  reflect.Value
+}
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// settingsOf lists the settings of c, pointing into c.
+func settingsOf(c *Config) []*setting {
+	var settings []*setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := f.Tag.Get("key")
+			if key == "" {
+				continue
+			}
+			key = prefix + key
+			if f.Type.Kind() == reflect.Struct && f.Type != timeType && !reflect.PointerTo(f.Type).Implements(unmarshalerType) {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			s := &setting{key: key, usage: f.Tag.Get("usage"), secret: f.Tag.Get("secret") == "true", field: v.Field(i)}
+			if env := f.Tag.Get("env"); env != "" {
+				s.env = strings.Split(env, ",")
+			}
+			if !s.secret {
+				s.flag = strings.NewReplacer(".", "-", "_", "-").Replace(key)
+			}
+			settings = append(settings, s)
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return settings
+}
+
+// set parses v according to the type of the setting. Types of their own,
+// such as Rate, parse themselves.
+func (s *setting) set(v string) error {
+	switch {
+	case s.field.Type() == durationType:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use e.g. 30s or 5m", v)
+		}
+		s.field.SetInt(int64(d))
+	case s.field.Type() == timeType:
+		t, err := parseDate(v)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, use YYYY-MM-DD", v)
+		}
+		s.field.Set(reflect.ValueOf(t))
+	case s.field.Addr().Type().Implements(unmarshalerType):
+		return s.field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(v))
+	case s.field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		s.field.SetInt(int64(n))
+	case s.field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		s.field.SetFloat(f)
+	case s.field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		s.field.SetBool(b)
+	default:
+		s.field.SetString(v)
+	}
+	return nil
+}
+
+// String formats the current value the way set parses it.
+func (s *setting) String() string {
+	switch v := s.field.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "none"
+		}
+		return v.Format(time.DateOnly)
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// apply sets the settings named in values. Unknown keys are errors, so
+// typos in files do not go unnoticed.
+func apply(settings []*setting, values map[string]string, source string) error {
+	byKey := make(map[string]*setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", source, key)
+		}
+		if err := s.set(values[key]); err != nil {
+			return fmt.Errorf("%s: %s: %w", source, key, err)
+		}
+	}
+	return nil
+}
+
+// envValues reads the environment variables of the settings. When a
+// setting has several, the first one set wins.
+func envValues(settings []*setting) map[string]string {
+	values := make(map[string]string)
+	for _, s := range settings {
+		for _, name := range s.env {
+			if v, ok := os.LookupEnv(name); ok && v != "" {
+				values[s.key] = v
+				break
+			}
+		}
+	}
+	return values
+}
+
+// readFile reads a YAML or TOML file, chosen by extension, into flattened
+// dotted keys.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(tree, "", values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(tree map[string]interface{}, prefix string, values map[string]string) error {
+	for k, v := range tree {
+		key := prefix + k
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(v, key+".", values); err != nil {
+				return err
+			}
+		case time.Time:
+			if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+				values[key] = v.Format(time.DateOnly)
+			} else {
+				values[key] = v.Format(time.RFC3339)
+			}
+		case nil:
+		case []interface{}:
+			return fmt.Errorf("%s: lists are not supported", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
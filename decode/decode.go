@@ -1,26 +1,79 @@
 package decode
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
-var ErrEmptyBody = errors.New("request body is empty")
+// MaxBodyBytes is the largest request body DecodeJSONBody and ReadBody
+// accept.
+const MaxBodyBytes = 1 << 20
 
+var (
+	ErrEmptyBody    = errors.New("request body is empty")
+	ErrBodyTooLarge = fmt.Errorf("request body is larger than %d bytes", MaxBodyBytes)
+	ErrTrailingData = errors.New("request body must contain a single JSON value")
+)
+
+// DecodeJSONBody decodes exactly one JSON value from the request body into
+// dst. Unknown fields, anything after the value and bodies larger than
+// MaxBodyBytes are rejected.
 func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	if r.Body == nil {
 		return ErrEmptyBody
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
 
 	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
 	err := decoder.Decode(dst)
 	if err != nil {
-		if err == io.EOF {
-			return ErrEmptyBody
+		return decodeError(err)
+	}
+
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return ErrBodyTooLarge
 		}
-		return err
+		return ErrTrailingData
 	}
 	return nil
 }
+
+// ReadBody reads the whole request body. Empty bodies and bodies larger
+// than MaxBodyBytes are rejected.
+func ReadBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, ErrEmptyBody
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return nil, ErrBodyTooLarge
+	case err != nil:
+		return nil, err
+	case len(bytes.TrimSpace(body)) == 0:
+		return nil, ErrEmptyBody
+	}
+	return body, nil
+}
+
+func decodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	switch {
+	case err == io.EOF:
+		return ErrEmptyBody
+	case errors.As(err, &maxErr):
+		return ErrBodyTooLarge
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
@@ -1,12 +1,64 @@
 package decode
 
 import (
+	models "ToDoProject/models"
 	"encoding/json"
 	"net/http"
 )
 
-func JSONError(w http.ResponseWriter, err error, status int) {
-	JSONResponse(w, status, map[string]string{"error": err.Error()})
+// Problem types used across the API. They are relative URI references, as
+// allowed by RFC 7807; generic HTTP errors use about:blank.
+const (
+	ProblemTypeValidation    = "/problems/validation-error"
+	ProblemTypeUnauthorized  = "/problems/unauthorized"
+	ProblemTypeForbidden     = "/problems/forbidden"
+	ProblemTypeNotFound      = "/problems/not-found"
+	ProblemTypeConflict      = "/problems/conflict"
+	ProblemTypeRateLimited   = "/problems/rate-limited"
+	ProblemTypeAccountLocked = "/problems/account-locked"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []models.FieldError `json:"errors,omitempty"`
+}
+
+// JSONError writes err as an application/problem+json response with the
+// given status.
+func JSONError(w http.ResponseWriter, r *http.Request, err error, status int) {
+	ProblemResponse(w, r, Problem{Status: status, Detail: err.Error()})
+}
+
+// Unauthorized writes a 401 problem for a failed authentication.
+func Unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	ProblemResponse(w, r, Problem{
+		Type:   ProblemTypeUnauthorized,
+		Title:  "Authentication failed",
+		Status: http.StatusUnauthorized,
+		Detail: err.Error(),
+	})
+}
+
+// ProblemResponse writes p, defaulting Type to about:blank, Title to the
+// status text and Instance to the request path.
+func ProblemResponse(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
 }
 
 func JSONResponse(w http.ResponseWriter, status int, payload interface{}) {
@@ -0,0 +1,94 @@
+package events
+
+import (
+	models "ToDoProject/models"
+	"sync"
+)
+
+const subscriberBuffer = 64
+
+// Hub fans todo events out to the in-process subscribers of each user.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[int]map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription delivers a user's events on C. C is closed when the
+// subscription is cancelled, when the subscriber falls too far behind or
+// when the hub is closed; a stream client is then expected to reconnect and
+// resume by event id.
+type Subscription struct {
+	C      <-chan models.TodoEvent
+	c      chan models.TodoEvent
+	userID int
+	hub    *Hub
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[int]map[*Subscription]struct{})}
+}
+
+func (h *Hub) Subscribe(userID int) *Subscription {
+	c := make(chan models.TodoEvent, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, userID: userID, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(c)
+		return sub
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+// Publish implements store.EventPublisher.
+func (h *Hub) Publish(event models.TodoEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[event.UserId] {
+		select {
+		case sub.c <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Close ends all subscriptions, now and in the future, so that streaming
+// handlers return and the servers can drain on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.subs {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+func (s *Subscription) Cancel() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subs[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subs, sub.userID)
+	}
+	close(sub.c)
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"ToDoProject/store"
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Listen relays events into hub. The streams consumer of the outbox relay
+// announces each event id once with NOTIFY, whichever replica committed
+// it, and every replica's listener hands it to its local subscribers.
+// Listen blocks until ctx is cancelled.
+func Listen(ctx context.Context, connStr string, s *store.TodoStore, hub *Hub) error {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("events: listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(store.NotifyChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established
+			// and notifications may have been missed; clients catch up by
+			// resuming from their last event id.
+			if n == nil {
+				continue
+			}
+			relay(ctx, s, hub, n.Extra)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+func relay(ctx context.Context, s *store.TodoStore, hub *Hub, payload string) {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return
+	}
+	event, err := s.GetEvent(ctx, id)
+	if err != nil {
+		log.Printf("events: loading event %d: %v", id, err)
+		return
+	}
+	hub.Publish(event)
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	replayLimit = 500
+	// HeartbeatInterval is how often Stream calls its heartbeat while no
+	// events arrive.
+	HeartbeatInterval = 25 * time.Second
+)
+
+// ErrSubscriptionEnded is returned by Stream when the hub ends the
+// subscription because the stream fell behind or the hub was closed.
+var ErrSubscriptionEnded = errors.New("events: subscription ended")
+
+// Stream hands the user's events to send: first those after the event
+// afterID, replayed from the outbox, then live ones from the hub. afterID 0
+// streams live events only.
+//
+// Event ids are taken when an event is inserted, so events can commit out
+// of id order. Replays therefore run in change sequence order from the
+// sequence number of the afterID event, and live events are skipped only
+// when the replay already sent them, never because a higher id went out
+// first. A client that resumes after reordered live events may get some of
+// them again, but never misses one.
+//
+// A non-nil heartbeat is called every HeartbeatInterval while no events
+// arrive. Stream returns nil when ctx is done, the error of send or
+// heartbeat, ErrSubscriptionEnded, or the store's error.
+func Stream(ctx context.Context, s *store.TodoStore, hub *Hub, userID int, afterID int64, send func(models.TodoEvent) error, heartbeat func() error) error {
+	// Subscribe before replaying so nothing committed in between is lost.
+	sub := hub.Subscribe(userID)
+	defer sub.Cancel()
+
+	resumed := afterID > 0
+	var replayed int64
+	if resumed {
+		seq, err := s.EventSeq(ctx, userID, afterID)
+		if err != nil {
+			return err
+		}
+		replayed = seq
+		for {
+			missed, err := s.EventsSince(ctx, userID, replayed, replayLimit)
+			if err != nil {
+				return err
+			}
+			for _, event := range missed {
+				if err := send(event); err != nil {
+					return err
+				}
+				replayed = event.Seq
+			}
+			if len(missed) < replayLimit {
+				break
+			}
+		}
+	}
+
+	var tick <-chan time.Time
+	if heartbeat != nil {
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return ErrSubscriptionEnded
+			}
+			// A user's events commit in sequence order, so everything at
+			// or below the last replayed number was read by the replay.
+			if resumed && event.Seq <= replayed {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
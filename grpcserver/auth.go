@@ -0,0 +1,111 @@
+package grpcserver
+
+import (
+	"ToDoProject/auth"
+	token "ToDoProject/jwttoken"
+	models "ToDoProject/models"
+	"ToDoProject/todopb"
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate validates the access token or personal access token in the
+// "authorization" metadata and stores the caller's auth.Principal in the
+// context, as the HTTP AuthMiddleware does.
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var header string
+	if values := md.Get("authorization"); len(values) > 0 {
+		header = values[0]
+	}
+	p, err := token.Authenticate(ctx, header)
+	if err != nil {
+		if !token.IsTokenError(err) {
+			return nil, toStatus("authenticate", err)
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+// userID returns the id of the caller. The interceptors reject calls without
+// a principal before they reach a service method.
+func userID(ctx context.Context) int {
+	p, _ := auth.FromContext(ctx)
+	return p.UserID
+}
+
+// UnaryAuthInterceptor authenticates unary calls.
+func UnaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamAuthInterceptor authenticates streaming calls.
+func StreamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// methodRequirements declares what each method requires of the caller, as
+// the endpoint tables in routes.go do for HTTP routes. Methods that are not
+// listed are refused.
+var methodRequirements = map[string]auth.Requirement{
+	todopb.TodoService_CreateTodo_FullMethodName: auth.Scope(models.ScopeTodosWrite),
+	todopb.TodoService_GetTodo_FullMethodName:    auth.Scope(models.ScopeTodosRead),
+	todopb.TodoService_ListTodos_FullMethodName:  auth.Scope(models.ScopeTodosRead),
+	todopb.TodoService_UpdateTodo_FullMethodName: auth.Scope(models.ScopeTodosWrite),
+	todopb.TodoService_DeleteTodo_FullMethodName: auth.Scope(models.ScopeTodosWrite),
+	todopb.TodoService_WatchTodos_FullMethodName: auth.Scope(models.ScopeTodosRead),
+}
+
+// checkMethod fails with PermissionDenied unless the caller meets the
+// requirement of method.
+func checkMethod(ctx context.Context, method string) error {
+	require, ok := methodRequirements[method]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "no permissions declared for %s", method)
+	}
+	p, _ := auth.FromContext(ctx)
+	if err := auth.Check(p, require); err != nil {
+		return toStatus(method, err)
+	}
+	return nil
+}
+
+// UnaryRequireInterceptor enforces methodRequirements on unary calls. It
+// must run after UnaryAuthInterceptor.
+func UnaryRequireInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkMethod(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamRequireInterceptor enforces methodRequirements on streaming calls.
+// It must run after StreamAuthInterceptor.
+func StreamRequireInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkMethod(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
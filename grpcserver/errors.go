@@ -0,0 +1,49 @@
+package grpcserver
+
+import (
+	"ToDoProject/store"
+	"errors"
+	"log"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusCodes maps the store's error kinds to gRPC codes, like problemKinds
+// does for HTTP statuses.
+var statusCodes = []struct {
+	kind error
+	code codes.Code
+}{
+	{store.ErrValidation, codes.InvalidArgument},
+	{store.ErrUnauthorized, codes.Unauthenticated},
+	{store.ErrForbidden, codes.PermissionDenied},
+	{store.ErrNotFound, codes.NotFound},
+	{store.ErrConflict, codes.Aborted},
+}
+
+// toStatus turns err into a gRPC status error. Domain errors keep their
+// message, with field errors as BadRequest details; anything else becomes a
+// bare Internal error and is logged.
+func toStatus(method string, err error) error {
+	for _, k := range statusCodes {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+		st := status.New(k.code, err.Error())
+		var domainErr *store.Error
+		if errors.As(err, &domainErr) && len(domainErr.Fields) > 0 {
+			br := &errdetails.BadRequest{}
+			for _, f := range domainErr.Fields {
+				br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+			}
+			if withDetails, err := st.WithDetails(br); err == nil {
+				st = withDetails
+			}
+		}
+		return st.Err()
+	}
+	log.Printf("grpc %s: %v", method, err)
+	return status.Error(codes.Internal, "internal server error")
+}
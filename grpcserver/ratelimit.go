@@ -0,0 +1,48 @@
+package grpcserver
+
+import (
+	"ToDoProject/ratelimit"
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// UnaryRateLimitInterceptor applies the per-user API limit of l to unary
+// calls. It must run after UnaryAuthInterceptor.
+func UnaryRateLimitInterceptor(l *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := allow(ctx, l); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimitInterceptor applies the per-user API limit of l to the
+// start of streaming calls. It must run after StreamAuthInterceptor.
+func StreamRateLimitInterceptor(l *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allow(ss.Context(), l); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allow fails with ResourceExhausted, carrying the wait as RetryInfo, when
+// the caller's bucket is empty.
+func allow(ctx context.Context, l *ratelimit.Limiter) error {
+	retryAfter, ok := l.AllowUser(ctx, userID(ctx))
+	if ok {
+		return nil
+	}
+	st := status.New(codes.ResourceExhausted, "too many requests")
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,213 @@
+// Package grpcserver serves the todo API over gRPC. It shares the store,
+// event hub and access tokens with the HTTP server.
+package grpcserver
+
+import (
+	"ToDoProject/events"
+	models "ToDoProject/models"
+	"ToDoProject/ratelimit"
+	"ToDoProject/store"
+	"ToDoProject/todopb"
+	"ToDoProject/validate"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	pageTokenPrefix = "v1:"
+)
+
+// Server implements todopb.TodoServiceServer.
+type Server struct {
+	todopb.UnimplementedTodoServiceServer
+	Store  *store.TodoStore
+	Events *events.Hub
+}
+
+// New returns a gRPC server with the todo service and the authentication,
+// permission and rate limiting interceptors registered. A nil limiter disables rate
+// limiting. Calls are traced, continuing the trace of incoming traceparent
+// metadata.
+func New(s *store.TodoStore, hub *events.Hub, limiter *ratelimit.Limiter) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(UnaryAuthInterceptor, UnaryRequireInterceptor, UnaryRateLimitInterceptor(limiter)),
+		grpc.ChainStreamInterceptor(StreamAuthInterceptor, StreamRequireInterceptor, StreamRateLimitInterceptor(limiter)),
+	)
+	todopb.RegisterTodoServiceServer(srv, &Server{Store: s, Events: hub})
+	return srv
+}
+
+func (s *Server) CreateTodo(ctx context.Context, req *todopb.CreateTodoRequest) (*todopb.Todo, error) {
+	r := models.TodoHandlerRequest{Title: req.GetTitle(), Description: req.GetDescription()}
+	if err := validate.Request(&r); err != nil {
+		return nil, toStatus("CreateTodo", err)
+	}
+	todo, err := s.Store.Create(ctx, userID(ctx), r.Title, r.Description, false)
+	if err != nil {
+		return nil, toStatus("CreateTodo", err)
+	}
+	return toProto(todo), nil
+}
+
+func (s *Server) GetTodo(ctx context.Context, req *todopb.GetTodoRequest) (*todopb.Todo, error) {
+	todo, err := s.Store.Get(ctx, userID(ctx), int(req.GetId()))
+	if err != nil {
+		return nil, toStatus("GetTodo", err)
+	}
+	return toProto(todo), nil
+}
+
+func (s *Server) ListTodos(ctx context.Context, req *todopb.ListTodosRequest) (*todopb.ListTodosResponse, error) {
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	m := models.TodoQueries{Done: req.Done}
+	if req.GetTitle() != "" {
+		title := req.GetTitle()
+		m.Title = &title
+	}
+	if req.GetDescription() != "" {
+		description := req.GetDescription()
+		m.Description = &description
+	}
+	if req.CreatedBefore != nil {
+		before := req.CreatedBefore.AsTime().Format(time.RFC3339Nano)
+		m.CreatedBefore = &before
+	}
+	if req.CreatedAfter != nil {
+		after := req.CreatedAfter.AsTime().Format(time.RFC3339Nano)
+		m.CreatedAfter = &after
+	}
+
+	todos, err := s.Store.TodoPage(ctx, userID(ctx), m, afterID, size+1)
+	if err != nil {
+		return nil, toStatus("ListTodos", err)
+	}
+	resp := &todopb.ListTodosResponse{}
+	if len(todos) > size {
+		todos = todos[:size]
+		resp.NextPageToken = encodePageToken(todos[size-1].ID)
+	}
+	for _, todo := range todos {
+		resp.Todos = append(resp.Todos, toProto(todo))
+	}
+	return resp, nil
+}
+
+func (s *Server) UpdateTodo(ctx context.Context, req *todopb.UpdateTodoRequest) (*todopb.Todo, error) {
+	r := models.TodoUpdateHandlerRequest{Title: req.Title, Description: req.Description, Done: req.Done}
+	if err := validate.Request(&r); err != nil {
+		return nil, toStatus("UpdateTodo", err)
+	}
+
+	var todo models.Todo
+	var err error
+	if req.BaseVersion != nil {
+		todo, err = s.Store.VersionedUpdate(ctx, userID(ctx), int(req.GetId()), int(req.GetBaseVersion()), r)
+	} else {
+		todo, err = s.Store.SoftUpdate(ctx, userID(ctx), int(req.GetId()), r)
+	}
+	if err != nil {
+		return nil, toStatus("UpdateTodo", err)
+	}
+	return toProto(todo), nil
+}
+
+func (s *Server) DeleteTodo(ctx context.Context, req *todopb.DeleteTodoRequest) (*todopb.Todo, error) {
+	var todo models.Todo
+	var err error
+	if req.BaseVersion != nil {
+		todo, err = s.Store.VersionedDelete(ctx, userID(ctx), int(req.GetId()), int(req.GetBaseVersion()))
+	} else {
+		todo, err = s.Store.Delete(ctx, userID(ctx), int(req.GetId()))
+	}
+	if err != nil {
+		return nil, toStatus("DeleteTodo", err)
+	}
+	return toProto(todo), nil
+}
+
+// WatchTodos replays the events after req.AfterEventId from the outbox and
+// then streams live events from the hub, like GET /todos/events.
+func (s *Server) WatchTodos(req *todopb.WatchTodosRequest, stream todopb.TodoService_WatchTodosServer) error {
+	var sendErr error
+	err := events.Stream(stream.Context(), s.Store, s.Events, userID(stream.Context()), req.GetAfterEventId(),
+		func(event models.TodoEvent) error {
+			sendErr = stream.Send(eventToProto(event))
+			return sendErr
+		},
+		nil,
+	)
+	switch {
+	case err == nil, sendErr != nil:
+		return err
+	case errors.Is(err, events.ErrSubscriptionEnded):
+		return status.Error(codes.Unavailable, "watch ended because the watcher fell behind or the server is shutting down; resume with after_event_id")
+	default:
+		return toStatus("WatchTodos", err)
+	}
+}
+
+func toProto(t models.Todo) *todopb.Todo {
+	return &todopb.Todo{
+		Id:          int64(t.ID),
+		UserId:      int64(t.UserId),
+		Title:       t.Title,
+		Description: t.Description,
+		CreatedAt:   timestamppb.New(t.CreatedAt),
+		Done:        t.Done,
+		Version:     int32(t.Version),
+	}
+}
+
+func eventToProto(e models.TodoEvent) *todopb.TodoEvent {
+	return &todopb.TodoEvent{
+		Id:        e.ID,
+		Type:      e.Type,
+		Todo:      toProto(e.Payload),
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+}
+
+func encodePageToken(lastID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(lastID)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	id, ok := strings.CutPrefix(string(raw), pageTokenPrefix)
+	if !ok {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.Atoi(id)
+}
@@ -3,9 +3,15 @@ package handlers
 import (
 	"ToDoProject/decode"
 	token "ToDoProject/jwttoken"
+	"ToDoProject/metrics"
 	models "ToDoProject/models"
+	"ToDoProject/ratelimit"
+	"ToDoProject/store"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 // LoginHandler godoc
@@ -16,38 +22,52 @@ import (
 // @Produce json
 // @Param login body models.LoginRequest true "Login payload"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 401 {object} decode.Problem
+// @Failure 429 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Router /login [post]
 func (h *TodoHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
-	if err := decode.DecodeJSONBody(w, r, &req); err != nil {
-		status := http.StatusBadRequest
-		msg := "invalid JSON"
-		if err == decode.ErrEmptyBody {
-			msg = "request body cannot be empty"
-		}
-		decode.JSONError(w, fmt.Errorf(msg+": %w", err), status)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	if req.Username == "" || req.Password == "" {
-		decode.JSONError(w, fmt.Errorf("username and password are required"), http.StatusBadRequest)
+	client := models.Client{IP: ratelimit.ClientIP(r, h.TrustForwardedFor), UserAgent: r.UserAgent()}
+	userID, err := h.Store.CheckUserCredentials(r.Context(), req.Username, req.Password, client)
+	var locked *store.LockedError
+	if errors.As(err, &locked) {
+		metrics.Login(false)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		decode.ProblemResponse(w, r, decode.Problem{
+			Type:   decode.ProblemTypeAccountLocked,
+			Title:  "Account temporarily locked",
+			Status: http.StatusTooManyRequests,
+			Detail: locked.Error(),
+		})
 		return
 	}
-
-	userID, err := h.Store.CheckUserCredentials(req.Username, req.Password)
+	if errors.Is(err, store.ErrUnauthorized) {
+		metrics.Login(false)
+		writeError(w, r, store.Unauthorized("invalid credentials"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	roles, err := h.Store.UserRoles(r.Context(), userID)
 	if err != nil {
-		decode.JSONError(w, fmt.Errorf("invalid credentials"), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
-	accessToken, refreshToken, err := token.GenerateTokens(userID)
+	accessToken, refreshToken, err := token.GenerateTokens(userID, roles)
 	if err != nil {
-		decode.JSONError(w, fmt.Errorf("could not generate tokens: %w", err), http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("could not generate tokens: %w", err))
 		return
 	}
+	metrics.Login(true)
 
 	decode.JSONResponse(w, http.StatusOK, map[string]interface{}{
 		"user_id":       userID,
@@ -64,35 +84,25 @@ func (h *TodoHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param register body models.RegisterRequest true "Register payload"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 409 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Router /register [post]
 func (h *TodoHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
-	if err := decode.DecodeJSONBody(w, r, &req); err != nil {
-		status := http.StatusBadRequest
-		msg := "invalid JSON"
-		if err == decode.ErrEmptyBody {
-			msg = "request body cannot be empty"
-		}
-		decode.JSONError(w, fmt.Errorf(msg+": %w", err), status)
-		return
-	}
-
-	if req.Username == "" || req.Password == "" {
-		decode.JSONError(w, fmt.Errorf("username and password are required"), http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	user, err := h.Store.CreateUser(req.Username, req.Password)
+	user, err := h.Store.CreateUser(r.Context(), req.Username, req.Password)
 	if err != nil {
-		decode.JSONError(w, fmt.Errorf("could not create user: %w", err), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	accessToken, refreshToken, err := token.GenerateTokens(user.ID)
+	accessToken, refreshToken, err := token.GenerateTokens(user.ID, nil)
 	if err != nil {
-		decode.JSONError(w, fmt.Errorf("could not generate tokens: %w", err), http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("could not generate tokens: %w", err))
 		return
 	}
 
@@ -102,3 +112,76 @@ func (h *TodoHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		"refresh_token": refreshToken,
 	})
 }
+
+// RefreshHandler godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshRequest true "Refresh payload"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} decode.Problem
+// @Failure 401 {object} decode.Problem
+// @Router /refresh [post]
+func (h *TodoHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	userID, err := token.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		writeError(w, r, store.Unauthorized("%v", err))
+		return
+	}
+	if err := h.Store.CheckUserActive(r.Context(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			err = store.Unauthorized("invalid refresh token")
+		}
+		writeError(w, r, err)
+		return
+	}
+	roles, err := h.Store.UserRoles(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	accessToken, _, err := token.GenerateTokens(userID, roles)
+	if err != nil {
+		writeError(w, r, fmt.Errorf("could not generate tokens: %w", err))
+		return
+	}
+
+	decode.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+	})
+}
+
+// authEventsLimit is how many auth events AuthEvents returns.
+const authEventsLimit = 100
+
+// AuthEvents godoc
+// @Summary List sign-in events
+// @Description Returns the latest successful and failed logins, lockouts and unlocks of the authenticated user's account, newest first.
+// @Tags account
+// @Produce json
+// @Success 200 {array} models.AuthEvent
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /account/auth-events [get]
+func (h *TodoHandler) AuthEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.Store.AuthEvents(r.Context(), userID, authEventsLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	decode.JSONResponse(w, http.StatusOK, events)
+}
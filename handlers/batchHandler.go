@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"ToDoProject/decode"
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const batchMaxOperations = 100
+
+// BatchTodos godoc
+// @Summary Run several todo operations
+// @Description Runs an ordered list of create, update (partial) and delete operations. By default they share one transaction and either all apply or none do; with "atomic": false each operation stands alone. A create may set "ref", and later operations may target the created todo with "id_ref" instead of "id". Every operation gets its own result with a status code; in atomic mode operations that were rolled back or never ran report 424.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param batch body models.BatchRequest true "Operations"
+// @Success 200 {object} models.BatchResponse
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 404 {object} models.BatchResponse
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /todos/batch [post]
+func (h *TodoHandler) BatchTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.BatchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if err := checkBatch(req.Operations); err != nil {
+		decode.JSONError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	ops := req.Operations
+	results := make([]models.BatchResult, len(ops))
+	refs := make(map[string]int)
+
+	if req.Atomic != nil && !*req.Atomic {
+		for i, op := range ops {
+			results[i] = runBatchOperation(r.Context(), h.Store, userID, i, op, refs)
+		}
+		decode.JSONResponse(w, http.StatusOK, models.BatchResponse{Results: results})
+		return
+	}
+
+	failed := -1
+	err := h.Store.Batch(r.Context(), func(b *store.Batch) error {
+		for i, op := range ops {
+			results[i] = runBatchOperation(r.Context(), b, userID, i, op, refs)
+			if results[i].Status != http.StatusOK {
+				failed = i
+				return errors.New(results[i].Error)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		decode.JSONResponse(w, http.StatusOK, models.BatchResponse{Results: results})
+		return
+	}
+	if failed < 0 {
+		writeError(w, r, err)
+		return
+	}
+
+	failure := results[failed]
+	for i, op := range ops {
+		results[i] = models.BatchResult{
+			Index:  i,
+			Ref:    op.Ref,
+			Status: http.StatusFailedDependency,
+			Error:  fmt.Sprintf("not applied: operation %d failed", failed),
+		}
+	}
+	results[failed] = failure
+	decode.JSONResponse(w, failure.Status, models.BatchResponse{Results: results})
+}
+
+// checkBatch rejects batches that can never run: too many operations,
+// unknown op names and references that don't point at an earlier create.
+func checkBatch(ops []models.BatchOperation) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("operations cannot be empty")
+	}
+	if len(ops) > batchMaxOperations {
+		return fmt.Errorf("at most %d operations per batch", batchMaxOperations)
+	}
+
+	refs := make(map[string]bool)
+	for i, op := range ops {
+		switch op.Op {
+		case models.BatchOpCreate:
+			if op.ID != 0 || op.IDRef != "" {
+				return fmt.Errorf("operation %d: create cannot target an id", i)
+			}
+		case models.BatchOpUpdate, models.BatchOpDelete:
+			if op.Ref != "" {
+				return fmt.Errorf("operation %d: only create can set ref", i)
+			}
+			if (op.ID == 0) == (op.IDRef == "") {
+				return fmt.Errorf("operation %d: exactly one of id and id_ref is required", i)
+			}
+			if op.IDRef != "" && !refs[op.IDRef] {
+				return fmt.Errorf("operation %d: id_ref %q does not name an earlier create", i, op.IDRef)
+			}
+		default:
+			return fmt.Errorf("operation %d: unknown op %q", i, op.Op)
+		}
+
+		if op.Ref != "" {
+			if refs[op.Ref] {
+				return fmt.Errorf("operation %d: duplicate ref %q", i, op.Ref)
+			}
+			refs[op.Ref] = true
+		}
+	}
+	return nil
+}
+
+// runBatchOperation runs one checked operation and records the id of
+// created todos under their ref.
+func runBatchOperation(ctx context.Context, wr todoWriter, userID int, index int, op models.BatchOperation, refs map[string]int) models.BatchResult {
+	result := models.BatchResult{Index: index, Ref: op.Ref}
+
+	id := op.ID
+	if op.IDRef != "" {
+		var ok bool
+		id, ok = refs[op.IDRef]
+		if !ok {
+			result.Status = http.StatusFailedDependency
+			result.Error = fmt.Sprintf("create %q did not succeed", op.IDRef)
+			return result
+		}
+	}
+
+	var todo models.Todo
+	var err error
+	switch op.Op {
+	case models.BatchOpCreate:
+		req := models.TodoHandlerRequest{}
+		if op.Data.Title != nil {
+			req.Title = *op.Data.Title
+		}
+		if op.Data.Description != nil {
+			req.Description = *op.Data.Description
+		}
+		todo, err = createTodo(ctx, wr, userID, req, op.Data.Done != nil && *op.Data.Done)
+	case models.BatchOpUpdate:
+		todo, err = patchTodo(ctx, wr, userID, id, op.Data)
+	case models.BatchOpDelete:
+		todo, err = deleteTodo(ctx, wr, userID, id)
+	}
+
+	if err != nil {
+		p := problemFor(err)
+		result.Status = p.Status
+		result.Error = p.Detail
+		return result
+	}
+	if op.Ref != "" {
+		refs[op.Ref] = todo.ID
+	}
+	result.Status = http.StatusOK
+	result.Todo = &todo
+	return result
+}
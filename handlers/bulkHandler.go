@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"ToDoProject/decode"
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"ToDoProject/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errDryRun = errors.New("dry run")
+
+type confirmationError struct {
+	matched int
+}
+
+func (e confirmationError) Error() string {
+	return fmt.Sprintf("%d todos match; repeat the request with header X-Confirm-Count: %d to proceed", e.matched, e.matched)
+}
+
+// BulkUpdateTodos godoc
+// @Summary Update todos matching a filter
+// @Description Applies the given fields to every todo matching the same filters as GET /todos (ordering and pagination are ignored). At least one filter is required, and unparseable filter values are rejected. Use dry_run=true to only count the matches. When more todos match than the configured threshold, the request must carry X-Confirm-Count with the matched count.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param done query bool false "Filter by done"
+// @Param title query string false "Filter by title"
+// @Param description query string false "Filter by description"
+// @Param created_at query string false "Filter by creation date"
+// @Param created_before query string false "Created before"
+// @Param created_after query string false "Created after"
+// @Param dry_run query bool false "Only count matching todos"
+// @Param X-Confirm-Count header int false "Matched count, required above the threshold"
+// @Param todo body models.TodoUpdateHandlerRequest true "Fields to set"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 428 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /todos [patch]
+func (h *TodoHandler) BulkUpdateTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.TodoUpdateHandlerRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Title == nil && req.Description == nil && req.Done == nil {
+		decode.JSONError(w, r, fmt.Errorf("no fields to update"), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := bulkFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var matched int
+	todos, err := h.Store.BulkUpdate(r.Context(), userID, filter, req, h.bulkCheck(r, &matched))
+	h.writeBulkResult(w, r, matched, todos, err)
+}
+
+// BulkDeleteTodos godoc
+// @Summary Delete todos matching a filter
+// @Description Deletes every todo matching the same filters as GET /todos (ordering and pagination are ignored). At least one filter is required, and unparseable filter values are rejected. Use dry_run=true to only count the matches. When more todos match than the configured threshold, the request must carry X-Confirm-Count with the matched count.
+// @Tags todos
+// @Produce json
+// @Param done query bool false "Filter by done"
+// @Param title query string false "Filter by title"
+// @Param description query string false "Filter by description"
+// @Param created_at query string false "Filter by creation date"
+// @Param created_before query string false "Created before"
+// @Param created_after query string false "Created after"
+// @Param dry_run query bool false "Only count matching todos"
+// @Param X-Confirm-Count header int false "Matched count, required above the threshold"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 428 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /todos [delete]
+func (h *TodoHandler) BulkDeleteTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := bulkFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var matched int
+	todos, err := h.Store.BulkDelete(r.Context(), userID, filter, h.bulkCheck(r, &matched))
+	h.writeBulkResult(w, r, matched, todos, err)
+}
+
+// bulkFilter reads the filter of a bulk request. Unlike GET /todos, it
+// rejects values it cannot parse rather than ignoring them, and it requires
+// at least one filter, so that a typo cannot widen the request to every
+// todo of the user.
+func bulkFilter(r *http.Request) (models.TodoQueries, error) {
+	query := r.URL.Query()
+	filter := utils.MakeQueriesStruct(r)
+
+	var fields []models.FieldError
+	if v := query.Get("done"); v != "" && filter.Done == nil {
+		fields = append(fields, models.FieldError{Field: "done", Message: "must be true or false"})
+	}
+	for _, name := range []string{"created_at", "created_before", "created_after"} {
+		if v := query.Get(name); v != "" && !validTime(v) {
+			fields = append(fields, models.FieldError{Field: name, Message: "must be a date like 2006-01-02 or an RFC 3339 time"})
+		}
+	}
+	if len(fields) > 0 {
+		return models.TodoQueries{}, store.Validation("invalid filter", fields...)
+	}
+
+	if filter.Done == nil && *filter.Title == "" && *filter.Description == "" &&
+		*filter.Timestamp == "" && *filter.CreatedBefore == "" && *filter.CreatedAfter == "" {
+		return models.TodoQueries{}, store.Validation("a bulk request needs at least one filter: done, title, description, created_at, created_before or created_after")
+	}
+	return filter, nil
+}
+
+func validTime(v string) bool {
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if _, err := time.Parse(layout, v); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkCheck returns the check run by the store once the matching todos are
+// known: it stores the count in matched, stops dry runs and enforces the
+// confirmation header above the threshold.
+func (h *TodoHandler) bulkCheck(r *http.Request, matched *int) func(int) error {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	confirm := r.Header.Get("X-Confirm-Count")
+	return func(n int) error {
+		*matched = n
+		if dryRun {
+			return errDryRun
+		}
+		if n > h.BulkConfirmThreshold && confirm != strconv.Itoa(n) {
+			return confirmationError{matched: n}
+		}
+		return nil
+	}
+}
+
+func (h *TodoHandler) writeBulkResult(w http.ResponseWriter, r *http.Request, matched int, todos []models.Todo, err error) {
+	var confirmErr confirmationError
+	switch {
+	case errors.Is(err, errDryRun):
+		decode.JSONResponse(w, http.StatusOK, models.BulkResult{Matched: matched, DryRun: true})
+	case errors.As(err, &confirmErr):
+		decode.JSONError(w, r, err, http.StatusPreconditionRequired)
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		decode.JSONResponse(w, http.StatusOK, models.BulkResult{Matched: matched, Todos: todos})
+	}
+}
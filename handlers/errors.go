@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"ToDoProject/decode"
+	"ToDoProject/logging"
+	"ToDoProject/store"
+	"errors"
+	"net/http"
+)
+
+// problemKinds maps the store's error kinds to their problem type, title
+// and status.
+var problemKinds = []struct {
+	kind   error
+	typ    string
+	title  string
+	status int
+}{
+	{store.ErrValidation, decode.ProblemTypeValidation, "Your request is not valid", http.StatusBadRequest},
+	{store.ErrUnauthorized, decode.ProblemTypeUnauthorized, "Authentication failed", http.StatusUnauthorized},
+	{store.ErrForbidden, decode.ProblemTypeForbidden, "Permission denied", http.StatusForbidden},
+	{store.ErrNotFound, decode.ProblemTypeNotFound, "Resource not found", http.StatusNotFound},
+	{store.ErrConflict, decode.ProblemTypeConflict, "Resource state conflict", http.StatusConflict},
+}
+
+// problemFor turns err into problem details. Domain errors keep their
+// message; anything else becomes a bare 500 so database and other internal
+// details never reach the client.
+func problemFor(err error) decode.Problem {
+	for _, k := range problemKinds {
+		if errors.Is(err, k.kind) {
+			p := decode.Problem{Type: k.typ, Title: k.title, Status: k.status, Detail: err.Error()}
+			var domainErr *store.Error
+			if errors.As(err, &domainErr) {
+				p.Errors = domainErr.Fields
+			}
+			return p
+		}
+	}
+	return decode.Problem{Status: http.StatusInternalServerError, Detail: "internal server error"}
+}
+
+// errorStatus returns the HTTP status code err maps to.
+func errorStatus(err error) int {
+	return problemFor(err).Status
+}
+
+// writeError is the single place where handler errors become responses.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFor(err)
+	if p.Status == http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+	}
+	decode.ProblemResponse(w, r, p)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"ToDoProject/decode"
+	"ToDoProject/events"
+	models "ToDoProject/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// StreamTodoEvents godoc
+// @Summary Stream todo changes
+// @Description Server-Sent Events stream of the authenticated user's todo changes. Each event has the outbox event id, an event name of created, updated or deleted, and the todo as JSON data. Send Last-Event-ID to resume after a disconnect; missed events are replayed in commit order, and events that committed out of id order may be sent again.
+// @Tags todos
+// @Produce text/event-stream
+// @Param Last-Event-ID header int false "Resume after this event id"
+// @Success 200 {object} models.TodoEvent
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /todos/events [get]
+func (h *TodoHandler) StreamTodoEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		decode.JSONError(w, r, fmt.Errorf("streaming is not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	var lastID int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			decode.JSONError(w, r, fmt.Errorf("invalid Last-Event-ID"), http.StatusBadRequest)
+			return
+		}
+		lastID = parsed
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events.Stream(r.Context(), h.Store, h.Events, userID, lastID,
+		func(event models.TodoEvent) error {
+			if err := writeEvent(w, event); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+		func() error {
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+	)
+}
+
+func writeEvent(w http.ResponseWriter, event models.TodoEvent) error {
+	data, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
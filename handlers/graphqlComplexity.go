@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// Page sizes of list fields. A list field multiplies the cost of its
+// selection by the number of items it may return.
+const (
+	graphqlDefaultPageSize = 20
+	graphqlMaxPageSize     = 100
+)
+
+// graphqlListArgs names the size argument of every list field.
+var graphqlListArgs = map[string]string{
+	"todos":   "first",
+	"history": "last",
+}
+
+// graphqlComplexity estimates the cost of the operation in query: every field
+// costs 1 plus the cost of its selection, multiplied by the page size for
+// list fields. Queries that do not parse cost nothing here; execution
+// reports their syntax errors.
+func graphqlComplexity(query, operationName string, variables map[string]interface{}) (int, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return 0, nil
+	}
+	op := doc.Operations.ForName(operationName)
+	if op == nil {
+		return 0, nil
+	}
+
+	c := &complexityCounter{doc: doc, op: op, variables: variables, visiting: map[string]bool{}}
+	return c.selectionSet(op.SelectionSet)
+}
+
+type complexityCounter struct {
+	doc       *ast.QueryDocument
+	op        *ast.OperationDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+func (c *complexityCounter) selectionSet(set ast.SelectionSet) (int, error) {
+	total := 0
+	for _, sel := range set {
+		var cost int
+		var err error
+		switch sel := sel.(type) {
+		case *ast.Field:
+			cost, err = c.field(sel)
+		case *ast.InlineFragment:
+			cost, err = c.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			cost, err = c.fragment(sel.Name)
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += cost
+		if total > graphqlMaxComplexity {
+			return total, nil
+		}
+	}
+	return total, nil
+}
+
+func (c *complexityCounter) field(f *ast.Field) (int, error) {
+	children, err := c.selectionSet(f.SelectionSet)
+	if err != nil {
+		return 0, err
+	}
+	argName, ok := graphqlListArgs[f.Name]
+	if !ok {
+		return 1 + children, nil
+	}
+	size, err := c.pageSize(f.Arguments.ForName(argName))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return 1 + size*children, nil
+}
+
+func (c *complexityCounter) fragment(name string) (int, error) {
+	frag := c.doc.Fragments.ForName(name)
+	if frag == nil || c.visiting[name] {
+		// Unknown and cyclic fragments fail validation during execution.
+		return 0, nil
+	}
+	c.visiting[name] = true
+	defer delete(c.visiting, name)
+	return c.selectionSet(frag.SelectionSet)
+}
+
+// pageSize returns the page size a list argument asks for, resolving
+// variables and their defaults.
+func (c *complexityCounter) pageSize(arg *ast.Argument) (int, error) {
+	if arg == nil {
+		return graphqlDefaultPageSize, nil
+	}
+	value := arg.Value
+	if value.Kind == ast.Variable {
+		if v, ok := c.variables[value.Raw]; ok {
+			return clampPageSize(v)
+		}
+		def := c.op.VariableDefinitions.ForName(value.Raw)
+		if def == nil || def.DefaultValue == nil {
+			return graphqlDefaultPageSize, nil
+		}
+		value = def.DefaultValue
+	}
+	v, err := value.Value(nil)
+	if err != nil {
+		return 0, err
+	}
+	return clampPageSize(v)
+}
+
+func clampPageSize(v interface{}) (int, error) {
+	var n int
+	switch v := v.(type) {
+	case nil:
+		return graphqlDefaultPageSize, nil
+	case int64:
+		n = int(v)
+	case float64:
+		n = int(v)
+	default:
+		return 0, errors.New("page size must be an integer")
+	}
+	switch {
+	case n < 0:
+		return 0, errors.New("page size must not be negative")
+	case n > graphqlMaxPageSize:
+		return graphqlMaxPageSize, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"ToDoProject/decode"
+	"ToDoProject/logging"
+	models "ToDoProject/models"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+// Limits applied to every GraphQL operation. See graphqlComplexity for how
+// the cost of an operation is computed.
+const (
+	graphqlMaxDepth      = 12
+	graphqlMaxComplexity = 5000
+)
+
+// GraphQL returns the handler of POST /graphql. Resolvers share the store,
+// validation and error mapping of the REST handlers.
+func (h *TodoHandler) GraphQL() http.HandlerFunc {
+	schema := graphql.MustParseSchema(graphqlSchema, &gqlResolver{h: h}, graphql.MaxDepth(graphqlMaxDepth))
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.serveGraphQL(w, r, schema)
+	}
+}
+
+// serveGraphQL godoc
+// @Summary GraphQL endpoint
+// @Description Run a GraphQL query or mutation over the authenticated user's todos. Operations above the complexity limit are rejected before they run.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body models.GraphQLRequest true "GraphQL request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} decode.Problem
+// @Failure 401 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /graphql [post]
+func (h *TodoHandler) serveGraphQL(w http.ResponseWriter, r *http.Request, schema *graphql.Schema) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.GraphQLRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	cost, err := graphqlComplexity(req.Query, req.OperationName, req.Variables)
+	if err == nil && cost > graphqlMaxComplexity {
+		err = fmt.Errorf("query complexity %d exceeds the limit of %d", cost, graphqlMaxComplexity)
+	}
+	if err != nil {
+		decode.JSONResponse(w, http.StatusOK, &graphql.Response{Errors: []*gqlerrors.QueryError{{Message: err.Error()}}})
+		return
+	}
+
+	ctx := withGQLLoaders(r.Context(), h.Store, userID)
+	resp := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, e := range resp.Errors {
+		var gqlErr *gqlError
+		if errors.As(e.ResolverError, &gqlErr) && gqlErr.cause != nil {
+			logging.FromContext(r.Context()).Error("graphql resolver failed", "path", e.Path, "error", gqlErr.cause)
+		}
+	}
+	decode.JSONResponse(w, http.StatusOK, resp)
+}
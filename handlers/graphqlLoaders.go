@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/graph-gophers/dataloader"
+)
+
+type gqlLoadersKey struct{}
+
+// gqlLoaders batch the per-todo and per-connection lookups of one GraphQL
+// request, so a page of todos costs one query for their owners and one for
+// their history, and the connections of a request one query for their
+// total counts, instead of one each.
+type gqlLoaders struct {
+	users   *dataloader.Loader
+	history *dataloader.Loader
+	counts  *dataloader.Loader
+}
+
+// historyKey asks for the last entries of a todo's history.
+type historyKey struct {
+	todoID int
+	last   int
+}
+
+func (k historyKey) String() string {
+	return strconv.Itoa(k.todoID) + ":" + strconv.Itoa(k.last)
+}
+
+func (k historyKey) Raw() interface{} {
+	return k
+}
+
+// countKey asks for the number of a user's todos matching a filter.
+type countKey struct {
+	userID int
+	filter models.TodoQueries
+}
+
+func (k countKey) String() string {
+	filter, _ := json.Marshal(k.filter)
+	return strconv.Itoa(k.userID) + ":" + string(filter)
+}
+
+func (k countKey) Raw() interface{} {
+	return k
+}
+
+func withGQLLoaders(ctx context.Context, s *store.TodoStore, userID int) context.Context {
+	loaders := &gqlLoaders{
+		users: dataloader.NewBatchedLoader(func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+			ids, err := loaderIDs(keys)
+			if err != nil {
+				return loaderErrors(keys, err)
+			}
+			users, err := s.GetUsers(ctx, ids)
+			if err != nil {
+				return loaderErrors(keys, err)
+			}
+			results := make([]*dataloader.Result, len(ids))
+			for i, id := range ids {
+				user, ok := users[id]
+				if !ok {
+					results[i] = &dataloader.Result{Error: store.NotFound("user %d not found", id)}
+					continue
+				}
+				results[i] = &dataloader.Result{Data: user}
+			}
+			return results
+		}),
+		// One query serves the longest history asked for; shorter ones
+		// are cut from it.
+		history: dataloader.NewBatchedLoader(func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+			ids := make([]int, len(keys))
+			last := 0
+			for i, key := range keys {
+				k := key.Raw().(historyKey)
+				ids[i], last = k.todoID, max(last, k.last)
+			}
+			history, err := s.HistoryFor(ctx, userID, ids, last)
+			if err != nil {
+				return loaderErrors(keys, err)
+			}
+			results := make([]*dataloader.Result, len(keys))
+			for i, key := range keys {
+				entries := history[ids[i]]
+				if n := key.Raw().(historyKey).last; len(entries) > n {
+					entries = entries[len(entries)-n:]
+				}
+				results[i] = &dataloader.Result{Data: entries}
+			}
+			return results
+		}),
+		counts: dataloader.NewBatchedLoader(func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+			byUser := make(map[int][]int)
+			for i, key := range keys {
+				user := key.Raw().(countKey).userID
+				byUser[user] = append(byUser[user], i)
+			}
+			results := make([]*dataloader.Result, len(keys))
+			for user, indexes := range byUser {
+				filters := make([]models.TodoQueries, len(indexes))
+				for j, i := range indexes {
+					filters[j] = keys[i].Raw().(countKey).filter
+				}
+				counts, err := s.CountTodos(ctx, user, filters)
+				for j, i := range indexes {
+					if err != nil {
+						results[i] = &dataloader.Result{Error: err}
+						continue
+					}
+					results[i] = &dataloader.Result{Data: counts[j]}
+				}
+			}
+			return results
+		}),
+	}
+	return context.WithValue(ctx, gqlLoadersKey{}, loaders)
+}
+
+func loadersFrom(ctx context.Context) *gqlLoaders {
+	return ctx.Value(gqlLoadersKey{}).(*gqlLoaders)
+}
+
+func (l *gqlLoaders) user(ctx context.Context, id int) (models.User, error) {
+	v, err := l.users.Load(ctx, loaderKey(id))()
+	if err != nil {
+		return models.User{}, err
+	}
+	return v.(models.User), nil
+}
+
+func (l *gqlLoaders) todoHistory(ctx context.Context, todoID, last int) ([]models.HistoryEntry, error) {
+	v, err := l.history.Load(ctx, historyKey{todoID: todoID, last: last})()
+	if err != nil {
+		return nil, err
+	}
+	return v.([]models.HistoryEntry), nil
+}
+
+func (l *gqlLoaders) countTodos(ctx context.Context, userID int, filter models.TodoQueries) (int, error) {
+	v, err := l.counts.Load(ctx, countKey{userID: userID, filter: filter})()
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// forgetTodos drops the cached histories and counts after a mutation.
+func (l *gqlLoaders) forgetTodos() {
+	l.history.ClearAll()
+	l.counts.ClearAll()
+}
+
+func loaderKey(id int) dataloader.Key {
+	return dataloader.StringKey(strconv.Itoa(id))
+}
+
+func loaderIDs(keys dataloader.Keys) ([]int, error) {
+	ids := make([]int, len(keys))
+	for i, key := range keys {
+		id, err := strconv.Atoi(key.String())
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func loaderErrors(keys dataloader.Keys, err error) []*dataloader.Result {
+	results := make([]*dataloader.Result, len(keys))
+	for i := range results {
+		results[i] = &dataloader.Result{Error: err}
+	}
+	return results
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"ToDoProject/auth"
+	"ToDoProject/decode"
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// gqlError carries the problem details of a resolver error: the detail
+// becomes the GraphQL error message, type, status and field errors become
+// extensions. Internal errors keep their cause, which serveGraphQL logs.
+type gqlError struct {
+	problem decode.Problem
+	cause   error
+}
+
+func (e *gqlError) Error() string {
+	return e.problem.Detail
+}
+
+func (e *gqlError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"type": e.problem.Type, "status": e.problem.Status}
+	if len(e.problem.Errors) > 0 {
+		ext["errors"] = e.problem.Errors
+	}
+	return ext
+}
+
+func toGQLError(err error) error {
+	p := problemFor(err)
+	if p.Status != http.StatusInternalServerError {
+		return &gqlError{problem: p}
+	}
+	p.Type = "about:blank"
+	return &gqlError{problem: p, cause: err}
+}
+
+// gqlUserID returns the authenticated user; serveGraphQL only runs
+// operations for requests that have one.
+func gqlUserID(ctx context.Context) int {
+	p, _ := auth.FromContext(ctx)
+	return p.UserID
+}
+
+// requireWrite fails mutations made with a token that lacks todos:write.
+// Queries are covered by the route's requirements.
+func requireWrite(ctx context.Context) error {
+	p, _ := auth.FromContext(ctx)
+	if err := auth.Check(p, auth.Scope(models.ScopeTodosWrite)); err != nil {
+		return toGQLError(err)
+	}
+	return nil
+}
+
+func parseGQLID(id graphql.ID) (int, error) {
+	n, err := strconv.Atoi(string(id))
+	if err != nil {
+		return 0, toGQLError(store.Validation("invalid id", models.FieldError{Field: "id", Message: "must be an integer"}))
+	}
+	return n, nil
+}
+
+type gqlResolver struct {
+	h *TodoHandler
+}
+
+func (r *gqlResolver) Viewer(ctx context.Context) (*userResolver, error) {
+	user, err := loadersFrom(ctx).user(ctx, gqlUserID(ctx))
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &userResolver{h: r.h, user: user}, nil
+}
+
+func (r *gqlResolver) Todo(ctx context.Context, args struct{ ID graphql.ID }) (*todoResolver, error) {
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := r.h.Store.Get(ctx, gqlUserID(ctx), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &todoResolver{h: r.h, todo: todo}, nil
+}
+
+func (r *gqlResolver) Todos(ctx context.Context, args todoConnectionArgs) (*todoConnectionResolver, error) {
+	return todoConnection(ctx, r.h, gqlUserID(ctx), args)
+}
+
+type createTodoInput struct {
+	Title       string
+	Description *string
+}
+
+func (r *gqlResolver) CreateTodo(ctx context.Context, args struct{ Input createTodoInput }) (*todoResolver, error) {
+	if err := requireWrite(ctx); err != nil {
+		return nil, err
+	}
+	req := models.TodoHandlerRequest{Title: args.Input.Title}
+	if args.Input.Description != nil {
+		req.Description = *args.Input.Description
+	}
+	todo, err := createTodo(ctx, r.h.Store, gqlUserID(ctx), req, false)
+	return r.mutated(ctx, todo, err)
+}
+
+type replaceTodoInput struct {
+	Title       string
+	Description string
+	Done        bool
+}
+
+func (r *gqlResolver) ReplaceTodo(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input replaceTodoInput
+}) (*todoResolver, error) {
+	if err := requireWrite(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	req := models.TodoUpdateHandlerRequest{Title: &args.Input.Title, Description: &args.Input.Description, Done: &args.Input.Done}
+	todo, err := putTodo(ctx, r.h.Store, gqlUserID(ctx), id, req)
+	return r.mutated(ctx, todo, err)
+}
+
+type updateTodoInput struct {
+	Title       *string
+	Description *string
+	Done        *bool
+}
+
+func (r *gqlResolver) UpdateTodo(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input updateTodoInput
+}) (*todoResolver, error) {
+	if err := requireWrite(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	req := models.TodoUpdateHandlerRequest{Title: args.Input.Title, Description: args.Input.Description, Done: args.Input.Done}
+	todo, err := patchTodo(ctx, r.h.Store, gqlUserID(ctx), id, req)
+	return r.mutated(ctx, todo, err)
+}
+
+func (r *gqlResolver) DeleteTodo(ctx context.Context, args struct{ ID graphql.ID }) (*todoResolver, error) {
+	if err := requireWrite(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseGQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := deleteTodo(ctx, r.h.Store, gqlUserID(ctx), id)
+	return r.mutated(ctx, todo, err)
+}
+
+// mutated wraps the result of a mutation, after which cached histories and
+// counts are stale.
+func (r *gqlResolver) mutated(ctx context.Context, todo models.Todo, err error) (*todoResolver, error) {
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	loadersFrom(ctx).forgetTodos()
+	return &todoResolver{h: r.h, todo: todo}, nil
+}
+
+type userResolver struct {
+	h    *TodoHandler
+	user models.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.user.ID))
+}
+
+func (r *userResolver) Username() string {
+	return r.user.Username
+}
+
+func (r *userResolver) Todos(ctx context.Context, args todoConnectionArgs) (*todoConnectionResolver, error) {
+	return todoConnection(ctx, r.h, r.user.ID, args)
+}
+
+type todoResolver struct {
+	h    *TodoHandler
+	todo models.Todo
+}
+
+func (r *todoResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.todo.ID))
+}
+
+func (r *todoResolver) Title() string {
+	return r.todo.Title
+}
+
+func (r *todoResolver) Description() string {
+	return r.todo.Description
+}
+
+func (r *todoResolver) Done() bool {
+	return r.todo.Done
+}
+
+func (r *todoResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.todo.CreatedAt}
+}
+
+func (r *todoResolver) Version() int32 {
+	return int32(r.todo.Version)
+}
+
+func (r *todoResolver) Owner(ctx context.Context) (*userResolver, error) {
+	user, err := loadersFrom(ctx).user(ctx, r.todo.UserId)
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	return &userResolver{h: r.h, user: user}, nil
+}
+
+func (r *todoResolver) History(ctx context.Context, args struct{ Last *int32 }) ([]*historyResolver, error) {
+	last := graphqlDefaultPageSize
+	if args.Last != nil {
+		var err error
+		if last, err = clampPageSize(float64(*args.Last)); err != nil {
+			return nil, toGQLError(store.Validation(err.Error(), models.FieldError{Field: "last", Message: err.Error()}))
+		}
+	}
+
+	entries, err := loadersFrom(ctx).todoHistory(ctx, r.todo.ID, last)
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+
+	resolvers := make([]*historyResolver, len(entries))
+	for i, e := range entries {
+		resolvers[i] = &historyResolver{h: r.h, entry: e}
+	}
+	return resolvers, nil
+}
+
+type historyResolver struct {
+	h     *TodoHandler
+	entry models.HistoryEntry
+}
+
+func (r *historyResolver) Seq() int32 {
+	return int32(r.entry.Seq)
+}
+
+func (r *historyResolver) ChangeType() string {
+	return r.entry.Type
+}
+
+func (r *historyResolver) Before() *todoResolver {
+	if r.entry.Old == nil {
+		return nil
+	}
+	return &todoResolver{h: r.h, todo: *r.entry.Old}
+}
+
+func (r *historyResolver) After() *todoResolver {
+	if r.entry.New == nil {
+		return nil
+	}
+	return &todoResolver{h: r.h, todo: *r.entry.New}
+}
+
+func (r *historyResolver) ChangedAt() graphql.Time {
+	return graphql.Time{Time: r.entry.CreatedAt}
+}
+
+type todoFilterInput struct {
+	Done          *bool
+	Title         *string
+	Description   *string
+	CreatedBefore *graphql.Time
+	CreatedAfter  *graphql.Time
+}
+
+func (f *todoFilterInput) queries() models.TodoQueries {
+	var m models.TodoQueries
+	if f == nil {
+		return m
+	}
+	m.Done, m.Title, m.Description = f.Done, f.Title, f.Description
+	if f.CreatedBefore != nil {
+		before := f.CreatedBefore.Format(time.RFC3339Nano)
+		m.CreatedBefore = &before
+	}
+	if f.CreatedAfter != nil {
+		after := f.CreatedAfter.Format(time.RFC3339Nano)
+		m.CreatedAfter = &after
+	}
+	return m
+}
+
+type todoConnectionArgs struct {
+	Filter *todoFilterInput
+	First  *int32
+	After  *string
+}
+
+const todoCursorPrefix = "todo:"
+
+func encodeTodoCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(todoCursorPrefix + strconv.Itoa(id)))
+}
+
+func decodeTodoCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(raw), todoCursorPrefix) {
+		if id, err := strconv.Atoi(strings.TrimPrefix(string(raw), todoCursorPrefix)); err == nil {
+			return id, nil
+		}
+	}
+	return 0, store.Validation("invalid cursor", models.FieldError{Field: "after", Message: "is not a cursor of this connection"})
+}
+
+// todoConnection loads one page of the user's todos. It fetches one todo
+// more than asked for to tell whether another page follows.
+func todoConnection(ctx context.Context, h *TodoHandler, userID int, args todoConnectionArgs) (*todoConnectionResolver, error) {
+	first := graphqlDefaultPageSize
+	if args.First != nil {
+		var err error
+		if first, err = clampPageSize(float64(*args.First)); err != nil {
+			return nil, toGQLError(store.Validation(err.Error(), models.FieldError{Field: "first", Message: err.Error()}))
+		}
+	}
+	afterID := 0
+	if args.After != nil {
+		var err error
+		if afterID, err = decodeTodoCursor(*args.After); err != nil {
+			return nil, toGQLError(err)
+		}
+	}
+
+	m := args.Filter.queries()
+	todos, err := h.Store.TodoPage(ctx, userID, m, afterID, first+1)
+	if err != nil {
+		return nil, toGQLError(err)
+	}
+	c := &todoConnectionResolver{h: h, userID: userID, filter: m}
+	if len(todos) > first {
+		todos, c.hasNextPage = todos[:first], true
+	}
+	c.todos = todos
+	return c, nil
+}
+
+type todoConnectionResolver struct {
+	h           *TodoHandler
+	userID      int
+	filter      models.TodoQueries
+	todos       []models.Todo
+	hasNextPage bool
+}
+
+func (r *todoConnectionResolver) Edges() []*todoEdgeResolver {
+	edges := make([]*todoEdgeResolver, len(r.todos))
+	for i, todo := range r.todos {
+		edges[i] = &todoEdgeResolver{h: r.h, todo: todo}
+	}
+	return edges
+}
+
+func (r *todoConnectionResolver) PageInfo() *pageInfoResolver {
+	p := &pageInfoResolver{hasNextPage: r.hasNextPage}
+	if len(r.todos) > 0 {
+		cursor := encodeTodoCursor(r.todos[len(r.todos)-1].ID)
+		p.endCursor = &cursor
+	}
+	return p
+}
+
+func (r *todoConnectionResolver) TotalCount(ctx context.Context) (int32, error) {
+	count, err := loadersFrom(ctx).countTodos(ctx, r.userID, r.filter)
+	if err != nil {
+		return 0, toGQLError(err)
+	}
+	return int32(count), nil
+}
+
+type todoEdgeResolver struct {
+	h    *TodoHandler
+	todo models.Todo
+}
+
+func (r *todoEdgeResolver) Cursor() string {
+	return encodeTodoCursor(r.todo.ID)
+}
+
+func (r *todoEdgeResolver) Node() *todoResolver {
+	return &todoResolver{h: r.h, todo: r.todo}
+}
+
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	return r.endCursor
+}
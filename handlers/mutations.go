@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"ToDoProject/validate"
+	"context"
+)
+
+// todoWriter is implemented by *store.TodoStore, where every call runs in its
+// own transaction, and by *store.Batch, where calls share one.
+type todoWriter interface {
+	Create(ctx context.Context, userId int, title string, description string, done bool) (models.Todo, error)
+	SoftUpdate(ctx context.Context, userId int, id int, model models.TodoUpdateHandlerRequest) (models.Todo, error)
+	HardUpdate(ctx context.Context, userId int, id int, model models.TodoUpdateHandlerRequest) (models.Todo, error)
+	Delete(ctx context.Context, userId int, id int) (models.Todo, error)
+}
+
+// The helpers below hold the validation and store calls shared by the REST
+// handlers, the batch endpoint and the WebSocket API, so every transport
+// behaves the same.
+
+func createTodo(ctx context.Context, wr todoWriter, userID int, req models.TodoHandlerRequest, done bool) (models.Todo, error) {
+	if err := validate.Request(&req); err != nil {
+		return models.Todo{}, err
+	}
+	return wr.Create(ctx, userID, req.Title, req.Description, done)
+}
+
+func putTodo(ctx context.Context, wr todoWriter, userID, id int, req models.TodoUpdateHandlerRequest) (models.Todo, error) {
+	fields := validate.Struct(&req)
+	if req.Title == nil {
+		fields = append(fields, models.FieldError{Field: "title", Message: "is required"})
+	}
+	if req.Description == nil {
+		fields = append(fields, models.FieldError{Field: "description", Message: "is required"})
+	}
+	if req.Done == nil {
+		fields = append(fields, models.FieldError{Field: "done", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return models.Todo{}, store.Validation("request validation failed", fields...)
+	}
+	return wr.HardUpdate(ctx, userID, id, req)
+}
+
+func patchTodo(ctx context.Context, wr todoWriter, userID, id int, req models.TodoUpdateHandlerRequest) (models.Todo, error) {
+	if err := validate.Request(&req); err != nil {
+		return models.Todo{}, err
+	}
+	return wr.SoftUpdate(ctx, userID, id, req)
+}
+
+func deleteTodo(ctx context.Context, wr todoWriter, userID, id int) (models.Todo, error) {
+	return wr.Delete(ctx, userID, id)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"ToDoProject/validate"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// patchedTodo is the todo document after a patch has been applied. Pointers
+// let validation tell an explicit null from a missing member.
+type patchedTodo struct {
+	ID          *int       `json:"id"`
+	UserId      *int       `json:"userId"`
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	CreatedAt   *time.Time `json:"created_at"`
+	Done        *bool      `json:"done"`
+	Version     *int       `json:"version"`
+}
+
+// patchDocument applies a JSON Merge Patch (RFC 7396) or JSON Patch
+// (RFC 6902) to the todo's JSON representation and persists the result.
+// The write is conditional on the version the patch was applied to, so
+// "test" operations hold for the todo that is actually replaced.
+func (h *TodoHandler) patchDocument(ctx context.Context, userID, id int, contentType string, patch []byte) (models.Todo, error) {
+	current, err := h.Store.Get(ctx, userID, id)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return models.Todo{}, err
+	}
+
+	var patched []byte
+	if contentType == contentTypeMergePatch {
+		patched, err = jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return models.Todo{}, store.Validation("invalid merge patch: " + err.Error())
+		}
+	} else {
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return models.Todo{}, store.Validation("invalid JSON patch: " + err.Error())
+		}
+		patched, err = ops.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return models.Todo{}, store.Conflict("%v", err)
+		}
+		if err != nil {
+			return models.Todo{}, store.Validation("could not apply JSON patch: " + err.Error())
+		}
+	}
+
+	req, err := validatePatchedTodo(current, patched)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	if err := validate.Request(&req); err != nil {
+		return models.Todo{}, err
+	}
+	return h.Store.VersionedUpdate(ctx, userID, id, current.Version, req)
+}
+
+// validatePatchedTodo checks the patched document and turns it into a full
+// update. Read-only members must be unchanged, title and done cannot be
+// null, and a null description clears it.
+func validatePatchedTodo(current models.Todo, patched []byte) (models.TodoUpdateHandlerRequest, error) {
+	var doc patchedTodo
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return models.TodoUpdateHandlerRequest{}, store.Validation("patched todo is invalid: " + err.Error())
+	}
+
+	var fields []models.FieldError
+	if doc.ID == nil || *doc.ID != current.ID {
+		fields = append(fields, models.FieldError{Field: "id", Message: "is read-only"})
+	}
+	if doc.UserId == nil || *doc.UserId != current.UserId {
+		fields = append(fields, models.FieldError{Field: "userId", Message: "is read-only"})
+	}
+	if doc.CreatedAt == nil || !doc.CreatedAt.Equal(current.CreatedAt) {
+		fields = append(fields, models.FieldError{Field: "created_at", Message: "is read-only"})
+	}
+	if doc.Version == nil || *doc.Version != current.Version {
+		fields = append(fields, models.FieldError{Field: "version", Message: "is read-only"})
+	}
+	if doc.Title == nil {
+		fields = append(fields, models.FieldError{Field: "title", Message: "cannot be null"})
+	}
+	if doc.Done == nil {
+		fields = append(fields, models.FieldError{Field: "done", Message: "cannot be null"})
+	}
+	if len(fields) > 0 {
+		return models.TodoUpdateHandlerRequest{}, store.Validation("patched todo is invalid", fields...)
+	}
+
+	description := ""
+	if doc.Description != nil {
+		description = *doc.Description
+	}
+
+	return models.TodoUpdateHandlerRequest{Title: doc.Title, Description: &description, Done: doc.Done}, nil
+}
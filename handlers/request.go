@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"ToDoProject/auth"
+	"ToDoProject/decode"
+	"ToDoProject/validate"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// requestUser returns the id of the authenticated user. For requests without
+// a principal, which only reach handlers registered without
+// jwttoken.AuthMiddleware, it writes a 401 and returns false.
+func requestUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	p, ok := auth.FromContext(r.Context())
+	if !ok {
+		decode.Unauthorized(w, r, auth.ErrUnauthenticated)
+		return 0, false
+	}
+	return p.UserID, true
+}
+
+// decodeRequest decodes the JSON body into dst and validates it. On failure
+// it writes the problem response and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := decode.DecodeJSONBody(w, r, dst); err != nil {
+		writeBodyError(w, r, err, "invalid JSON")
+		return false
+	}
+	if err := validate.Request(dst); err != nil {
+		writeError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// readRequest reads the raw body for handlers that parse it themselves. On
+// failure it writes the problem response and returns false.
+func readRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := decode.ReadBody(w, r)
+	if err != nil {
+		writeBodyError(w, r, err, "could not read body")
+		return nil, false
+	}
+	return body, true
+}
+
+// writeBodyError writes the problem response for an error reading the
+// body, prefixing errors other than empty and oversized bodies with what.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	switch {
+	case errors.Is(err, decode.ErrEmptyBody):
+		decode.JSONError(w, r, fmt.Errorf("request body cannot be empty"), http.StatusBadRequest)
+	case errors.Is(err, decode.ErrBodyTooLarge):
+		decode.JSONError(w, r, err, http.StatusRequestEntityTooLarge)
+	default:
+		decode.JSONError(w, r, fmt.Errorf("%s: %w", what, err), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"ToDoProject/decode"
+	"ToDoProject/logging"
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"ToDoProject/validate"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	syncPullLimit = 500
+	syncPushLimit = 500
+	syncTokenTag  = "v1:"
+)
+
+// PullChanges godoc
+// @Summary Pull changes since a sync token
+// @Description Returns the latest state of every todo that changed since the opaque sync token, with tombstones for deleted todos. Without a token it returns a snapshot of all todos. Keep calling with the returned token while has_more is true.
+// @Tags sync
+// @Produce json
+// @Param since query string false "Sync token from a previous pull"
+// @Success 200 {object} models.SyncPullResponse
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /sync [get]
+func (h *TodoHandler) PullChanges(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		todos, seq, err := h.Store.Snapshot(r.Context(), userID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		changes := make([]models.TodoChange, 0, len(todos))
+		for i := range todos {
+			changes = append(changes, models.TodoChange{TodoId: todos[i].ID, Todo: &todos[i]})
+		}
+		decode.JSONResponse(w, http.StatusOK, models.SyncPullResponse{Changes: changes, Token: encodeSyncToken(seq)})
+		return
+	}
+
+	seq, err := decodeSyncToken(since)
+	if err != nil {
+		decode.JSONError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	changes, last, more, err := h.Store.ChangesSince(r.Context(), userID, seq, syncPullLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if changes == nil {
+		changes = []models.TodoChange{}
+	}
+	decode.JSONResponse(w, http.StatusOK, models.SyncPullResponse{Changes: changes, Token: encodeSyncToken(last), HasMore: more})
+}
+
+// PushChanges godoc
+// @Summary Push offline changes
+// @Description Applies a batch of client-side changes in client_timestamp order, each in its own transaction. Updates and deletes carry the base_version the client last saw and are applied only when it is still the current version; otherwise they are returned in conflicts together with the server's todo, and the client must rebase and retry. A create is applied once per client_id; pushing it again returns the todo it created. Every change is reported in applied or conflicts, including changes that failed with an internal error. Pull afterwards to receive the resulting state.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param changes body models.SyncPushRequest true "Client changes"
+// @Success 200 {object} models.SyncPushResponse
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /sync [post]
+func (h *TodoHandler) PushChanges(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.SyncPushRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if len(req.Changes) > syncPushLimit {
+		decode.JSONError(w, r, fmt.Errorf("at most %d changes per request", syncPushLimit), http.StatusBadRequest)
+		return
+	}
+
+	changes := req.Changes
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].ClientTimestamp.Before(changes[j].ClientTimestamp)
+	})
+
+	resp := models.SyncPushResponse{Applied: []models.SyncApplied{}, Conflicts: []models.SyncConflict{}}
+	for _, change := range changes {
+		todo, err := h.applySyncChange(r.Context(), userID, change)
+
+		var conflict *store.ConflictError
+		switch {
+		case err == nil:
+			resp.Applied = append(resp.Applied, models.SyncApplied{ClientId: change.ClientId, Op: change.Op, Todo: todo})
+		case errors.As(err, &conflict):
+			resp.Conflicts = append(resp.Conflicts, models.SyncConflict{
+				ClientId: change.ClientId,
+				Reason:   models.SyncConflictVersion,
+				Current:  &conflict.Current,
+			})
+		case errors.Is(err, store.ErrNotFound):
+			resp.Conflicts = append(resp.Conflicts, models.SyncConflict{ClientId: change.ClientId, Reason: models.SyncConflictNotFound})
+		case errors.Is(err, store.ErrValidation):
+			resp.Conflicts = append(resp.Conflicts, models.SyncConflict{
+				ClientId: change.ClientId,
+				Reason:   models.SyncConflictInvalid,
+				Detail:   err.Error(),
+			})
+		default:
+			logging.FromContext(r.Context()).Error("sync change failed", "client_id", change.ClientId, "error", err)
+			resp.Conflicts = append(resp.Conflicts, models.SyncConflict{
+				ClientId: change.ClientId,
+				Reason:   models.SyncConflictError,
+				Detail:   problemFor(err).Detail,
+			})
+		}
+	}
+
+	decode.JSONResponse(w, http.StatusOK, resp)
+}
+
+func (h *TodoHandler) applySyncChange(ctx context.Context, userID int, change models.SyncClientChange) (models.Todo, error) {
+	switch change.Op {
+	case models.SyncOpCreate:
+		req := models.TodoHandlerRequest{}
+		if change.Fields.Title != nil {
+			req.Title = *change.Fields.Title
+		}
+		if change.Fields.Description != nil {
+			req.Description = *change.Fields.Description
+		}
+		if err := validate.Request(&req); err != nil {
+			return models.Todo{}, err
+		}
+		done := change.Fields.Done != nil && *change.Fields.Done
+		return h.Store.SyncCreate(ctx, userID, change.ClientId, req.Title, req.Description, done)
+
+	case models.SyncOpUpdate, models.SyncOpDelete:
+		if change.TodoId == 0 || change.BaseVersion == 0 {
+			return models.Todo{}, store.Validation("todo_id and base_version are required")
+		}
+		if change.Op == models.SyncOpUpdate {
+			if err := validate.Request(&change.Fields); err != nil {
+				return models.Todo{}, err
+			}
+			return h.Store.VersionedUpdate(ctx, userID, change.TodoId, change.BaseVersion, change.Fields)
+		}
+		return h.Store.VersionedDelete(ctx, userID, change.TodoId, change.BaseVersion)
+	}
+	return models.Todo{}, store.Validation(fmt.Sprintf("unknown op %q", change.Op))
+}
+
+func encodeSyncToken(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenTag + strconv.FormatInt(seq, 10)))
+}
+
+func decodeSyncToken(token string) (int64, error) {
+	invalid := errors.New("invalid sync token")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, invalid
+	}
+	seqStr, ok := strings.CutPrefix(string(raw), syncTokenTag)
+	if !ok {
+		return 0, invalid
+	}
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, invalid
+	}
+	return seq, nil
+}
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"ToDoProject/decode"
+	"ToDoProject/events"
 	models "ToDoProject/models"
+	"ToDoProject/ratelimit"
 	"ToDoProject/store"
 	"ToDoProject/utils"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
 
@@ -14,7 +17,17 @@ import (
 )
 
 type TodoHandler struct {
-	Store *store.TodoStore
+	Store  *store.TodoStore
+	Events *events.Hub
+	// BulkConfirmThreshold is the number of matched todos above which bulk
+	// updates and deletes require the X-Confirm-Count header.
+	BulkConfirmThreshold int
+	// TrustForwardedFor takes the client address recorded with auth
+	// events from X-Forwarded-For, as the rate limiter does.
+	TrustForwardedFor bool
+	// Limiter applies the per-user API limit to WebSocket messages, which
+	// do not pass through the HTTP middleware. Nil disables it.
+	Limiter *ratelimit.Limiter
 }
 
 // CreateTodo godoc
@@ -25,25 +38,24 @@ type TodoHandler struct {
 // @Produce json
 // @Param todo body models.TodoHandlerRequest true "Todo Data"
 // @Success 200 {object} models.Todo
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Security ApiKeyAuth
 // @Router /todos [post]
 func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 	var req models.TodoHandlerRequest
-	if err := decode.DecodeJSONBody(w, r, &req); err != nil {
-		if err == decode.ErrEmptyBody {
-			decode.JSONError(w, fmt.Errorf("request body cannot be empty"), http.StatusBadRequest)
-			return
-		}
-		decode.JSONError(w, fmt.Errorf("invalid JSON: %w", err), http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	todo, err := h.Store.Create(userID, req.Title, req.Description)
+	todo, err := createTodo(r.Context(), h.Store, userID, req, false)
 	if err != nil {
-		decode.JSONError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -52,42 +64,81 @@ func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 
 // ListTodos godoc
 // @Summary List todos
-// @Description Get all todos of the authenticated user
+// @Description Get all todos of the authenticated user. sort, order, limit and offset values outside their allowed values are rejected with 400.
 // @Tags todos
 // @Produce json
 // @Param done query bool false "Filter by done"
 // @Param title query string false "Filter by title"
 // @Param description query string false "Filter by description"
 // @Param createdAt query string false "Filter by creation date"
-// @Param order query string false "Order by"
-// @Param sort query string false "Sort value"
+// @Param created_before query string false "Created before"
+// @Param created_after query string false "Created after"
+// @Param order query string false "Sort direction: asc or desc"
+// @Param sort query string false "Sort column: id, title, description, done, created_at or version"
 // @Param limit query int false "Limit results"
 // @Param offset query int false "Offset results"
 // @Success 200 {array} models.Todo
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Security ApiKeyAuth
 // @Router /todos [get]
 func (h *TodoHandler) ListTodos(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 	var todos []models.Todo
 	var err error
 
 	queries := utils.MakeQueriesStruct(r)
 
 	if utils.CheckQueries(queries) {
-		todos, err = h.Store.List(userID)
+		todos, err = h.Store.List(r.Context(), userID)
 	} else {
-		todos, err = h.Store.FilteredList(userID, queries)
+		todos, err = h.Store.FilteredList(r.Context(), userID, queries)
 	}
 
 	if err != nil {
-		decode.JSONError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(todos)
 }
 
+// GetTodo godoc
+// @Summary Get a todo
+// @Description Get a todo of the authenticated user by ID
+// @Tags todos
+// @Produce json
+// @Param id path int true "Todo ID"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 404 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /todos/{id} [get]
+func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		decode.JSONError(w, r, fmt.Errorf("invalid id"), http.StatusBadRequest)
+		return
+	}
+
+	todo, err := h.Store.Get(r.Context(), userID, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	decode.JSONResponse(w, http.StatusOK, todo)
+}
+
 // PutTodo godoc
 // @Summary Update a todo
 // @Description Fully update a todo by ID
@@ -97,37 +148,32 @@ func (h *TodoHandler) ListTodos(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "Todo ID"
 // @Param todo body models.TodoUpdateHandlerRequest true "Todo Data"
 // @Success 200 {object} models.Todo
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 404 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Security ApiKeyAuth
 // @Router /todos/{id} [put]
 func (h *TodoHandler) PutTodo(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		decode.JSONError(w, fmt.Errorf("invalid id"), http.StatusBadRequest)
+		decode.JSONError(w, r, fmt.Errorf("invalid id"), http.StatusBadRequest)
 		return
 	}
 
 	var req models.TodoUpdateHandlerRequest
-	if err := decode.DecodeJSONBody(w, r, &req); err != nil {
-		if err == decode.ErrEmptyBody {
-			decode.JSONError(w, fmt.Errorf("request body cannot be empty"), http.StatusBadRequest)
-			return
-		}
-		decode.JSONError(w, fmt.Errorf("invalid JSON: %w", err), http.StatusBadRequest)
-		return
-	}
-
-	if req.Title == nil || req.Description == nil || req.Done == nil {
-		decode.JSONError(w, fmt.Errorf("all fields are required for PUT"), http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	todo, err := h.Store.HardUpdate(userID, id, req)
+	todo, err := putTodo(r.Context(), h.Store, userID, id, req)
 	if err != nil {
-		decode.JSONError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -137,39 +183,69 @@ func (h *TodoHandler) PutTodo(w http.ResponseWriter, r *http.Request) {
 
 // PatchTodo godoc
 // @Summary Partially update a todo
-// @Description Update only some fields of a todo by ID
+// @Description Update only some fields of a todo by ID. With application/json, fields that are missing or null are left alone. With application/merge-patch+json (RFC 7396) a null description clears it. With application/json-patch+json (RFC 6902) the operations, including "test", apply to the todo's JSON representation. id, userId, created_at and version are read-only.
 // @Tags todos
-// @Accept json
+// @Accept json,application/merge-patch+json,application/json-patch+json
 // @Produce json
 // @Param id path int true "Todo ID"
 // @Param todo body models.TodoUpdateHandlerRequest true "Todo Data"
 // @Success 200 {object} models.Todo
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 404 {object} decode.Problem
+// @Failure 409 {object} decode.Problem
+// @Failure 415 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Security ApiKeyAuth
 // @Router /todos/{id} [patch]
 func (h *TodoHandler) PatchTodo(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		decode.JSONError(w, fmt.Errorf("invalid id"), http.StatusBadRequest)
+		decode.JSONError(w, r, fmt.Errorf("invalid id"), http.StatusBadRequest)
 		return
 	}
 
-	var req models.TodoUpdateHandlerRequest
-	if err := decode.DecodeJSONBody(w, r, &req); err != nil {
-		if err == decode.ErrEmptyBody {
-			decode.JSONError(w, fmt.Errorf("request body cannot be empty"), http.StatusBadRequest)
+	contentType := "application/json"
+	if v := r.Header.Get("Content-Type"); v != "" {
+		contentType, _, err = mime.ParseMediaType(v)
+		if err != nil {
+			decode.JSONError(w, r, fmt.Errorf("invalid Content-Type"), http.StatusUnsupportedMediaType)
 			return
 		}
-		decode.JSONError(w, fmt.Errorf("invalid JSON: %w", err), http.StatusBadRequest)
+	}
+
+	switch contentType {
+	case "application/json":
+	case contentTypeMergePatch, contentTypeJSONPatch:
+		patch, ok := readRequest(w, r)
+		if !ok {
+			return
+		}
+		todo, err := h.patchDocument(r.Context(), userID, id, contentType, patch)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		decode.JSONResponse(w, http.StatusOK, todo)
+		return
+	default:
+		decode.JSONError(w, r, fmt.Errorf("unsupported Content-Type %q", contentType), http.StatusUnsupportedMediaType)
 		return
 	}
 
-	todo, err := h.Store.SoftUpdate(userID, id, req)
+	var req models.TodoUpdateHandlerRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	todo, err := patchTodo(r.Context(), h.Store, userID, id, req)
 	if err != nil {
-		decode.JSONError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -184,24 +260,29 @@ func (h *TodoHandler) PatchTodo(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Todo ID"
 // @Success 200 {object} models.Todo
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 404 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
 // @Security ApiKeyAuth
 // @Router /todos/{id} [delete]
 func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
 	id, idErr := strconv.Atoi(idStr)
 	if idErr != nil {
-		decode.JSONError(w, idErr, http.StatusBadRequest)
+		decode.JSONError(w, r, idErr, http.StatusBadRequest)
 		return
 	}
 
-	todo, err := h.Store.Delete(userID, id)
+	todo, err := deleteTodo(r.Context(), h.Store, userID, id)
 	if err != nil {
-		decode.JSONError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"ToDoProject/auth"
+	"ToDoProject/decode"
+	models "ToDoProject/models"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	mux "github.com/gorilla/mux"
+)
+
+// CreatePersonalToken godoc
+// @Summary Create a personal access token
+// @Description Issue a token for scripts and integrations with the given scopes (todos:read, todos:write, account) and an optional expiry. Send it like an access token. A personal access token can only issue tokens with scopes it carries itself; other scopes are refused with 403. The token is only shown in this response.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param token body models.PersonalTokenRequest true "Token name, scopes and expiry"
+// @Success 201 {object} models.CreatedPersonalToken
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 409 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /account/tokens [post]
+func (h *TodoHandler) CreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	p, ok := auth.FromContext(r.Context())
+	if !ok {
+		decode.Unauthorized(w, r, auth.ErrUnauthenticated)
+		return
+	}
+
+	var req models.PersonalTokenRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	t, err := h.Store.CreatePersonalToken(r.Context(), p.UserID, req.Name, req.Scopes, req.ExpiresAt, p.Scopes)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	decode.JSONResponse(w, http.StatusCreated, t)
+}
+
+// ListPersonalTokens godoc
+// @Summary List personal access tokens
+// @Description List the authenticated user's personal access tokens with their scopes, expiry and when they were last used. The tokens themselves are not returned.
+// @Tags account
+// @Produce json
+// @Success 200 {array} models.PersonalToken
+// @Failure 403 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /account/tokens [get]
+func (h *TodoHandler) ListPersonalTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.Store.PersonalTokens(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	decode.JSONResponse(w, http.StatusOK, tokens)
+}
+
+// RevokePersonalToken godoc
+// @Summary Revoke a personal access token
+// @Description Delete a personal access token of the authenticated user. Requests with it fail from then on.
+// @Tags account
+// @Param id path int true "Token ID"
+// @Success 204
+// @Failure 400 {object} decode.Problem
+// @Failure 403 {object} decode.Problem
+// @Failure 404 {object} decode.Problem
+// @Failure 500 {object} decode.Problem
+// @Security ApiKeyAuth
+// @Router /account/tokens/{id} [delete]
+func (h *TodoHandler) RevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		decode.JSONError(w, r, fmt.Errorf("invalid id"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.RevokePersonalToken(r.Context(), userID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
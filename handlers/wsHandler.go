@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"ToDoProject/auth"
+	"ToDoProject/decode"
+	token "ToDoProject/jwttoken"
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 50 * time.Second
+	wsMaxMessage   = 64 << 10
+	wsSendBuffer   = 64
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// wsRequest is a message sent by the client. ID is echoed back in the
+// matching ack or error so the client can correlate responses.
+type wsRequest struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Topic  string          `json:"topic,omitempty"`
+	TodoID int             `json:"todo_id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// wsMessage is a message sent by the server: an "ack" or "error" for a
+// client request, or an "event" for a change on a subscribed topic.
+type wsMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Event   string `json:"event,omitempty"`
+	EventID int64  `json:"event_id,omitempty"`
+	// RetryAfter is the number of seconds to wait after a 429 error.
+	RetryAfter int         `json:"retry_after,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// ServeWS godoc
+// @Summary WebSocket API
+// @Description Upgrades to a WebSocket carrying subscribe/unsubscribe requests for the "todos" and "todo:{id}" topics, create/patch/put/delete mutations acknowledged by request id, and change events for subscribed topics. Project topics are deferred until projects are modelled. Authenticate with an access token or personal access token in the Authorization header or, for browsers, the access_token query parameter. Subscribing requires the todos:read scope and mutations todos:write. When the token expires the server closes the socket with code 1008; reconnect with a fresh token.
+// @Tags todos
+// @Param access_token query string false "Access token or personal access token when the Authorization header cannot be set"
+// @Success 101
+// @Failure 401 {object} decode.Problem
+// @Router /ws [get]
+func (h *TodoHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
+	tokenString := r.Header.Get("Authorization")
+	if tokenString == "" {
+		tokenString = r.URL.Query().Get("access_token")
+	}
+	p, err := token.Authenticate(r.Context(), tokenString)
+	if err != nil {
+		if !token.IsTokenError(err) {
+			writeError(w, r, err)
+			return
+		}
+		decode.Unauthorized(w, r, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &wsConn{
+		h:      h,
+		conn:   conn,
+		p:      p,
+		userID: p.UserID,
+		send:   make(chan wsMessage, wsSendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]bool),
+	}
+	c.run(r.Context())
+}
+
+type wsConn struct {
+	h      *TodoHandler
+	conn   *websocket.Conn
+	p      auth.Principal
+	userID int
+	send   chan wsMessage
+	done   chan struct{}
+	once   sync.Once
+
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+func (c *wsConn) run(ctx context.Context) {
+	sub := c.h.Events.Subscribe(c.userID)
+	defer sub.Cancel()
+	go c.writeLoop(sub.C)
+
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	defer c.close()
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		// Only read errors end the connection; a message that does not
+		// decode into a request, malformed or of the wrong shape, is
+		// answered like any other bad request.
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.reply(wsMessage{Type: "error", Status: http.StatusBadRequest, Error: "invalid JSON: " + err.Error()})
+			continue
+		}
+		c.reply(c.handle(ctx, req))
+	}
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// reply queues msg for the writer, dropping the connection if the client is
+// not keeping up.
+func (c *wsConn) reply(msg wsMessage) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+// writeLoop is the connection's only writer. It also ends the connection
+// when the token it was opened with expires, since the socket is not
+// authenticated again.
+func (c *wsConn) writeLoop(events <-chan models.TodoEvent) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer c.close()
+
+	var expired <-chan time.Time
+	if !c.p.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.p.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		var msg wsMessage
+		select {
+		case <-c.done:
+			return
+		case msg = <-c.send:
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !c.subscribed(event.TodoId) {
+				continue
+			}
+			msg = wsMessage{Type: "event", Event: event.Type, EventID: event.ID, Data: event.Payload}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			continue
+		case <-expired:
+			reason := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, token.ErrTokenExpired.Error())
+			c.conn.WriteControl(websocket.CloseMessage, reason, time.Now().Add(wsWriteTimeout))
+			return
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := c.conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+}
+
+func (c *wsConn) subscribed(todoID int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics["todos"] || c.topics["todo:"+strconv.Itoa(todoID)]
+}
+
+func (c *wsConn) handle(ctx context.Context, req wsRequest) wsMessage {
+	if retryAfter, ok := c.h.Limiter.AllowUser(ctx, c.userID); !ok {
+		wait := int(math.Ceil(retryAfter.Seconds()))
+		return wsMessage{
+			Type:       "error",
+			ID:         req.ID,
+			Status:     http.StatusTooManyRequests,
+			Error:      fmt.Sprintf("too many requests, retry in %d seconds", wait),
+			RetryAfter: wait,
+		}
+	}
+	todo, err := c.dispatch(ctx, req)
+	if err != nil {
+		p := problemFor(err)
+		return wsMessage{Type: "error", ID: req.ID, Status: p.Status, Error: p.Detail}
+	}
+	if todo == nil {
+		return wsMessage{Type: "ack", ID: req.ID, Status: http.StatusOK}
+	}
+	return wsMessage{Type: "ack", ID: req.ID, Status: http.StatusOK, Data: todo}
+}
+
+// wsRequirements declares what each message type requires of the caller,
+// as the endpoint tables in routes.go do for HTTP routes. Every type
+// dispatch handles must be listed.
+var wsRequirements = map[string]auth.Requirement{
+	"subscribe":   auth.Scope(models.ScopeTodosRead),
+	"unsubscribe": auth.Scope(models.ScopeTodosRead),
+	"create":      auth.Scope(models.ScopeTodosWrite),
+	"put":         auth.Scope(models.ScopeTodosWrite),
+	"patch":       auth.Scope(models.ScopeTodosWrite),
+	"delete":      auth.Scope(models.ScopeTodosWrite),
+}
+
+func (c *wsConn) dispatch(ctx context.Context, req wsRequest) (*models.Todo, error) {
+	require, ok := wsRequirements[req.Type]
+	if !ok {
+		return nil, store.Validation(fmt.Sprintf("unknown message type %q", req.Type))
+	}
+	if err := auth.Check(c.p, require); err != nil {
+		return nil, err
+	}
+	switch req.Type {
+	case "subscribe", "unsubscribe":
+		topic, err := parseTopic(req.Topic)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		if req.Type == "subscribe" {
+			c.topics[topic] = true
+		} else {
+			delete(c.topics, topic)
+		}
+		c.mu.Unlock()
+		return nil, nil
+
+	case "create":
+		var body models.TodoHandlerRequest
+		if err := decodeData(req.Data, &body); err != nil {
+			return nil, err
+		}
+		todo, err := createTodo(ctx, c.h.Store, c.userID, body, false)
+		return &todo, err
+
+	case "put", "patch":
+		var body models.TodoUpdateHandlerRequest
+		if err := decodeData(req.Data, &body); err != nil {
+			return nil, err
+		}
+		var todo models.Todo
+		var err error
+		if req.Type == "put" {
+			todo, err = putTodo(ctx, c.h.Store, c.userID, req.TodoID, body)
+		} else {
+			todo, err = patchTodo(ctx, c.h.Store, c.userID, req.TodoID, body)
+		}
+		return &todo, err
+
+	case "delete":
+		todo, err := deleteTodo(ctx, c.h.Store, c.userID, req.TodoID)
+		return &todo, err
+	}
+	return nil, store.Validation(fmt.Sprintf("unknown message type %q", req.Type))
+}
+
+// parseTopic accepts "todos" for every todo of the user and "todo:{id}" for
+// a single todo.
+func parseTopic(topic string) (string, error) {
+	if topic == "todos" {
+		return topic, nil
+	}
+	if idStr, ok := strings.CutPrefix(topic, "todo:"); ok {
+		if _, err := strconv.Atoi(idStr); err == nil {
+			return topic, nil
+		}
+	}
+	return "", store.Validation(fmt.Sprintf("unknown topic %q", topic))
+}
+
+func decodeData(data json.RawMessage, dst interface{}) error {
+	if len(data) == 0 {
+		return store.Validation(decode.ErrEmptyBody.Error())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return store.Validation("invalid JSON: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package health serves the probes an orchestrator uses to decide whether to
+// restart the server or route traffic to it, and a diagnostics endpoint for
+// operators. They are served next to, not inside, the versioned API and are
+// not part of the Swagger documentation.
+package health
+
+import (
+	"ToDoProject/config"
+	"ToDoProject/decode"
+	"ToDoProject/lifecycle"
+	"ToDoProject/store"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Version and Commit identify the build. Set them with
+//
+//	go build -ldflags "-X ToDoProject/health.Version=1.4.0 -X ToDoProject/health.Commit=$(git rev-parse HEAD)"
+//
+// Without them the commit is taken from the VCS information Go embeds.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// pingTimeout bounds the database check of a readiness probe.
+const pingTimeout = 2 * time.Second
+
+type Handler struct {
+	Store   *store.TodoStore
+	App     *lifecycle.App
+	Config  *config.Config
+	Started time.Time
+}
+
+// Check is the outcome of one readiness check.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Healthz is the liveness probe. It only reports that the process serves
+// requests; dependencies are left to Readyz, so a database outage does not
+// get every replica restarted.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	decode.JSONResponse(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// Readyz is the readiness probe. It answers 503 unless the database
+// answers, all migrations are applied, every component is running and
+// shutdown has not begun, listing each check.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := []Check{h.checkDatabase(r.Context()), h.checkMigrations(r.Context())}
+	for _, s := range h.App.Status() {
+		checks = append(checks, Check{Name: s.Name, OK: s.State == lifecycle.StateRunning, Detail: componentDetail(s)})
+	}
+	if h.App.Stopping() {
+		checks = append(checks, Check{Name: "shutdown", Detail: "shutting down"})
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	decode.JSONResponse(w, code, map[string]interface{}{"status": status, "checks": checks})
+}
+
+func (h *Handler) checkDatabase(ctx context.Context) Check {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := h.Store.DB.PingContext(ctx); err != nil {
+		return Check{Name: "database", Detail: err.Error()}
+	}
+	return Check{Name: "database", OK: true}
+}
+
+// checkMigrations fails while the schema is older than this build expects,
+// e.g. during a rollout before "todoadmin migrate" has run.
+func (h *Handler) checkMigrations(ctx context.Context) Check {
+	migrations, err := h.Store.Migrations(ctx)
+	if err != nil {
+		return Check{Name: "migrations", Detail: err.Error()}
+	}
+	var pending []string
+	latest := ""
+	for _, m := range migrations {
+		if m.AppliedAt.IsZero() {
+			pending = append(pending, m.Name)
+		} else {
+			latest = m.Name
+		}
+	}
+	if len(pending) > 0 {
+		return Check{Name: "migrations", Detail: "pending: " + strings.Join(pending, ", ")}
+	}
+	return Check{Name: "migrations", OK: true, Detail: latest}
+}
+
+func componentDetail(s lifecycle.Status) string {
+	if s.Error != "" {
+		return string(s.State) + ": " + s.Error
+	}
+	return string(s.State)
+}
+
+// Info reports the build, uptime, configuration with secrets redacted,
+// component states and database pool statistics.
+func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
+	stats := h.Store.DB.Stats()
+	decode.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"build": map[string]interface{}{
+			"version":    Version,
+			"commit":     commit(),
+			"go_version": runtime.Version(),
+		},
+		"started_at": h.Started.UTC().Format(time.RFC3339),
+		"uptime":     time.Since(h.Started).Round(time.Second).String(),
+		"config":     h.Config.Summary(),
+		"components": h.App.Status(),
+		"db_pool": map[string]interface{}{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration":        stats.WaitDuration.String(),
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_idle_time_closed": stats.MaxIdleTimeClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		},
+	})
+}
+
+// commit returns Commit, or the revision recorded by the go command, with
+// a "-dirty" suffix for builds from a modified tree.
+func commit() string {
+	if Commit != "" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, dirty := "unknown", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty {
+		return fmt.Sprintf("%s-dirty", revision)
+	}
+	return revision
+}
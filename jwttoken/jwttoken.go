@@ -1,27 +1,65 @@
 package jwttoken
 
 import (
-	recovery "ToDoProject/safety"
+	"ToDoProject/auth"
+	"ToDoProject/config"
+	"ToDoProject/decode"
+	"ToDoProject/logging"
+	models "ToDoProject/models"
+	"ToDoProject/store"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
 )
 
-var accessTokenDuration = 24 * time.Hour
-var refreshTokenDuration = 7 * 24 * time.Hour
+var (
+	secret               []byte
+	accessTokenDuration  = 24 * time.Hour
+	refreshTokenDuration = 7 * 24 * time.Hour
+)
+
+// Configure sets the signing secret and token lifetimes. It must be called
+// before tokens are issued or parsed.
+func Configure(c config.JWT) {
+	secret = []byte(c.Secret)
+	accessTokenDuration = c.AccessTokenTTL
+	refreshTokenDuration = c.RefreshTokenTTL
+}
 
-func GenerateTokens(userID int) (accessToken string, refreshToken string, err error) {
+var errNoSecret = errors.New("jwttoken: signing secret not configured")
+
+// signingKey is the jwt.Keyfunc for tokens issued by GenerateTokens.
+func signingKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+	if len(secret) == 0 {
+		return nil, errNoSecret
+	}
+	return secret, nil
+}
+
+// GenerateTokens issues an access token carrying the user's roles and a
+// refresh token.
+func GenerateTokens(userID int, roles []string) (accessToken string, refreshToken string, err error) {
+	if len(secret) == 0 {
+		return "", "", errNoSecret
+	}
 	accessClaims := jwt.MapClaims{
 		"user_id": userID,
+		"roles":   roles,
 		"exp":     time.Now().Add(accessTokenDuration).Unix(),
 	}
 	access := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessToken, err = access.SignedString(recovery.GetJwt())
+	accessToken, err = access.SignedString(secret)
 	if err != nil {
 		return "", "", err
 	}
@@ -32,7 +70,7 @@ func GenerateTokens(userID int) (accessToken string, refreshToken string, err er
 		"type":    "refresh",
 	}
 	refresh := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshToken, err = refresh.SignedString(recovery.GetJwt())
+	refreshToken, err = refresh.SignedString(secret)
 	if err != nil {
 		return "", "", err
 	}
@@ -40,69 +78,199 @@ func GenerateTokens(userID int) (accessToken string, refreshToken string, err er
 	return accessToken, refreshToken, nil
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			http.Error(w, "missing token", http.StatusUnauthorized)
-			return
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// ParseAccessToken validates a signed access token, with or without a
+// "Bearer " prefix, and returns the principal it was issued for. Refresh
+// tokens are rejected.
+func ParseAccessToken(tokenString string) (auth.Principal, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	if tokenString == "" {
+		return auth.Principal{}, ErrMissingToken
+	}
+
+	parsedToken, err := jwt.Parse(tokenString, signingKey)
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return auth.Principal{}, ErrTokenExpired
 		}
+		return auth.Principal{}, ErrInvalidToken
+	}
 
-		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	if !parsedToken.Valid {
+		return auth.Principal{}, ErrInvalidToken
+	}
 
-		parsedToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return recovery.GetJwt(), nil
-		})
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] == "refresh" {
+		return auth.Principal{}, fmt.Errorf("%w: invalid claims", ErrInvalidToken)
+	}
 
-		if err != nil {
-			if errors.Is(err, jwt.ErrTokenExpired) {
-				http.Error(w, "token expired", http.StatusUnauthorized)
-				return
+	userIDFloat, ok := claims["user_id"].(float64)
+	if !ok {
+		return auth.Principal{}, fmt.Errorf("%w: invalid user_id", ErrInvalidToken)
+	}
+	// Tokens issued before roles existed have none.
+	var roles []string
+	if list, ok := claims["roles"].([]interface{}); ok {
+		for _, role := range list {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
 			}
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
 		}
+	}
+	p := auth.AccessPrincipal(int(userIDFloat), roles)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		p.ExpiresAt = exp.Time
+	}
+	return p, nil
+}
 
-		if !parsedToken.Valid {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
-		}
+// AccountChecker tells whether a user may still use the tokens issued to
+// them and which roles they hold now. It fails with store.ErrUnauthorized
+// for disabled users and with store.ErrNotFound for deleted ones.
+// *store.TodoStore implements it.
+type AccountChecker interface {
+	ActiveUserRoles(ctx context.Context, id int) ([]string, error)
+}
 
-		claims, ok := parsedToken.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "invalid claims", http.StatusUnauthorized)
-			return
-		}
+var accounts AccountChecker
 
-		userIDFloat, ok := claims["user_id"].(float64)
-		if !ok {
-			http.Error(w, "invalid user_id", http.StatusUnauthorized)
+// UseAccountCheck makes VerifyAccessToken, and so every authenticated API,
+// reject the access tokens of users that c reports as disabled or deleted
+// and take their roles from c rather than from the token.
+func UseAccountCheck(c AccountChecker) {
+	accounts = c
+}
+
+// VerifyAccessToken validates an access token like ParseAccessToken and
+// then checks that its user is still active and loads their roles, so that
+// disabling a user or revoking a role takes effect before their tokens
+// expire.
+func VerifyAccessToken(ctx context.Context, tokenString string) (auth.Principal, error) {
+	p, err := ParseAccessToken(tokenString)
+	if err != nil || accounts == nil {
+		return p, err
+	}
+	roles, err := accounts.ActiveUserRoles(ctx, p.UserID)
+	if errors.Is(err, store.ErrUnauthorized) || errors.Is(err, store.ErrNotFound) {
+		return auth.Principal{}, fmt.Errorf("%w: user disabled or deleted", ErrInvalidToken)
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	p.Roles = roles
+	return p, nil
+}
+
+// IsTokenError reports whether err says the token was missing or bad,
+// rather than that it could not be checked.
+func IsTokenError(err error) bool {
+	return errorType(err) != "_OTHER"
+}
+
+// tracer creates the span of AuthMiddleware.
+var tracer = otel.Tracer("ToDoProject/jwttoken")
+
+// PersonalTokenVerifier looks up personal access tokens. It fails with
+// store.ErrUnauthorized for tokens that are unknown, expired or revoked.
+// *store.TodoStore implements it.
+type PersonalTokenVerifier interface {
+	VerifyPersonalToken(ctx context.Context, token string) (userID int, scopes []string, expiresAt *time.Time, err error)
+}
+
+var personalTokens PersonalTokenVerifier
+
+// UsePersonalTokens makes AuthMiddleware accept personal access tokens,
+// checked by v, alongside access tokens.
+func UsePersonalTokens(v PersonalTokenVerifier) {
+	personalTokens = v
+}
+
+// Authenticate checks an Authorization header value, which holds either an
+// access token or a personal access token, and returns the caller. It is
+// shared by AuthMiddleware, the WebSocket handler and the gRPC server. Use
+// IsTokenError to tell bad tokens from failures to check them.
+func Authenticate(ctx context.Context, header string) (auth.Principal, error) {
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+	if !strings.HasPrefix(tokenString, models.PersonalTokenPrefix) || personalTokens == nil {
+		return VerifyAccessToken(ctx, tokenString)
+	}
+	userID, scopes, expiresAt, err := personalTokens.VerifyPersonalToken(ctx, tokenString)
+	if errors.Is(err, store.ErrUnauthorized) {
+		return auth.Principal{}, ErrInvalidToken
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	p := auth.Principal{UserID: userID, Scopes: scopes, TokenType: auth.PersonalToken}
+	if expiresAt != nil {
+		p.ExpiresAt = *expiresAt
+	}
+	return p, nil
+}
+
+// AuthMiddleware rejects requests without a valid access token or personal
+// access token and puts the caller's auth.Principal into the request
+// context. Checking the token gets a span of its own, which ends before the
+// request is handed on.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "AuthMiddleware")
+		p, err := Authenticate(ctx, r.Header.Get("Authorization"))
+		if err != nil {
+			span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+			span.End()
+			if !IsTokenError(err) {
+				// The token could not be checked at all, e.g. because
+				// the database is down.
+				logging.FromContext(r.Context()).Error("checking token", "error", err)
+				decode.ProblemResponse(w, r, decode.Problem{Status: http.StatusInternalServerError, Detail: "internal server error"})
+				return
+			}
+			decode.Unauthorized(w, r, err)
 			return
 		}
-		userID := int(userIDFloat)
+		span.SetAttributes(semconv.EnduserID(strconv.Itoa(p.UserID)))
+		span.End()
 
-		ctx := context.WithValue(r.Context(), "user_id", userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		logging.SetUser(r.Context(), p.UserID)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
 	})
 }
 
-func RefreshToken(refreshToken string) (newAccessToken string, err error) {
-	parsed, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		return recovery.GetJwt(), nil
-	})
+// errorType names the kind of token error for span attributes, without the
+// details that would make every value unique.
+func errorType(err error) string {
+	for _, kind := range []error{ErrMissingToken, ErrTokenExpired, ErrInvalidToken} {
+		if errors.Is(err, kind) {
+			return kind.Error()
+		}
+	}
+	return "_OTHER"
+}
+
+// ParseRefreshToken validates a refresh token and returns the user id it was
+// issued for.
+func ParseRefreshToken(refreshToken string) (int, error) {
+	parsed, err := jwt.Parse(refreshToken, signingKey)
 	if err != nil || !parsed.Valid {
-		return "", fmt.Errorf("invalid refresh token")
+		return 0, fmt.Errorf("invalid refresh token")
 	}
 
 	claims, ok := parsed.Claims.(jwt.MapClaims)
 	if !ok || claims["type"] != "refresh" {
-		return "", fmt.Errorf("invalid refresh token claims")
+		return 0, fmt.Errorf("invalid refresh token claims")
 	}
 
-	userID := int(claims["user_id"].(float64))
-	newAccess, _, err := GenerateTokens(userID)
-	return newAccess, err
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid refresh token claims")
+	}
+	return int(userID), nil
 }
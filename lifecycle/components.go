@@ -0,0 +1,185 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+type funcComponent struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// Func makes a component from a start and a stop function, either of which
+// may be nil.
+func Func(name string, start, stop func(ctx context.Context) error) Component {
+	return &funcComponent{name: name, start: start, stop: stop}
+}
+
+func (f *funcComponent) Name() string {
+	return f.name
+}
+
+func (f *funcComponent) Start(ctx context.Context) error {
+	if f.start == nil {
+		return nil
+	}
+	return f.start(ctx)
+}
+
+func (f *funcComponent) Stop(ctx context.Context) error {
+	if f.stop == nil {
+		return nil
+	}
+	return f.stop(ctx)
+}
+
+type worker struct {
+	name   string
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan struct{}
+	failed chan error
+}
+
+// Worker makes a component from a background loop such as a relay or a
+// scheduler. run is started in a goroutine and must return when its context
+// is cancelled; Stop cancels it and waits for it to return. A run that
+// returns an error before being stopped shuts the application down.
+func Worker(name string, run func(ctx context.Context) error) Component {
+	return &worker{name: name, run: run}
+}
+
+func (w *worker) Name() string {
+	return w.name
+}
+
+func (w *worker) Start(ctx context.Context) error {
+	// The worker outlives ctx, which ends when shutdown begins; it is
+	// cancelled in Stop, in its turn.
+	ctx, w.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	w.done = make(chan struct{})
+	w.failed = make(chan error, 1)
+	go func() {
+		defer close(w.done)
+		defer close(w.failed)
+		if err := w.run(ctx); err != nil && ctx.Err() == nil {
+			w.failed <- err
+		}
+	}()
+	return nil
+}
+
+func (w *worker) Stop(ctx context.Context) error {
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *worker) Failed() <-chan error {
+	return w.failed
+}
+
+type httpServer struct {
+	name   string
+	server *http.Server
+	failed chan error
+}
+
+// HTTPServer serves server.Addr. Stop stops accepting connections and waits
+// for in-flight requests; requests still running at the deadline are cut
+// off.
+func HTTPServer(name string, server *http.Server) Component {
+	return &httpServer{name: name, server: server}
+}
+
+func (s *httpServer) Name() string {
+	return s.name
+}
+
+func (s *httpServer) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	s.failed = make(chan error, 1)
+	go func() {
+		defer close(s.failed)
+		if err := s.server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			s.failed <- err
+		}
+	}()
+	return nil
+}
+
+func (s *httpServer) Stop(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
+	}
+	return nil
+}
+
+func (s *httpServer) Failed() <-chan error {
+	return s.failed
+}
+
+type grpcServer struct {
+	name   string
+	addr   string
+	server *grpc.Server
+	failed chan error
+}
+
+// GRPCServer serves server on addr. Stop waits for in-flight calls like
+// HTTPServer does.
+func GRPCServer(name, addr string, server *grpc.Server) Component {
+	return &grpcServer{name: name, addr: addr, server: server}
+}
+
+func (s *grpcServer) Name() string {
+	return s.name
+}
+
+func (s *grpcServer) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.failed = make(chan error, 1)
+	go func() {
+		defer close(s.failed)
+		if err := s.server.Serve(ln); err != nil {
+			s.failed <- err
+		}
+	}()
+	return nil
+}
+
+func (s *grpcServer) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *grpcServer) Failed() <-chan error {
+	return s.failed
+}
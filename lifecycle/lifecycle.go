@@ -0,0 +1,171 @@
+// Package lifecycle starts the parts of the application in order and stops
+// them in reverse order on shutdown, so that what is started first, like
+// the database pool, is closed last:
+//
+//	app := lifecycle.New(30 * time.Second)
+//	app.Add(lifecycle.Func("database", nil, func(context.Context) error { return db.Close() }))
+//	app.Add(lifecycle.Worker("outbox relay", relay.Run))
+//	app.Add(lifecycle.HTTPServer("http", server))
+//	err := app.Run(ctx)
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Component is a part of the application with a lifetime. Start must return
+// once the component is running, leaving long-running work to goroutines.
+// Stop must return when the component has stopped or ctx is done.
+type Component interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// failer is implemented by components that can fail while running. The
+// first error on Failed shuts the application down.
+type failer interface {
+	Failed() <-chan error
+}
+
+// State is where a component is in its lifetime.
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateRunning  State = "running"
+	StateFailed   State = "failed"
+	StateStopping State = "stopping"
+	StateStopped  State = "stopped"
+)
+
+// Status reports the state of one component; Error is set for failed
+// components.
+type Status struct {
+	Name  string `json:"name"`
+	State State  `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// App runs a list of components.
+type App struct {
+	// ShutdownTimeout bounds the time all components together get to stop.
+	ShutdownTimeout time.Duration
+	// Logf reports progress; it defaults to log.Printf.
+	Logf func(format string, args ...interface{})
+
+	components []Component
+
+	mu       sync.Mutex
+	statuses []Status
+	stopping bool
+}
+
+func New(shutdownTimeout time.Duration) *App {
+	return &App{ShutdownTimeout: shutdownTimeout, Logf: log.Printf}
+}
+
+// Add appends c. Components start in the order they are added and stop in
+// the reverse order.
+func (a *App) Add(c Component) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.components = append(a.components, c)
+	a.statuses = append(a.statuses, Status{Name: c.Name(), State: StatePending})
+}
+
+// Status returns the state of every component, in start order.
+func (a *App) Status() []Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Status(nil), a.statuses...)
+}
+
+// Stopping reports whether shutdown has begun.
+func (a *App) Stopping() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopping
+}
+
+func (a *App) setState(i int, state State, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.statuses[i].State = state
+	if err != nil {
+		a.statuses[i].Error = err.Error()
+	}
+}
+
+// Run starts the components, waits until ctx is done or a component fails,
+// and stops the components that were started. It returns the error that
+// caused the shutdown, if any, joined with the errors of stopping.
+func (a *App) Run(ctx context.Context) error {
+	failed := make(chan error, len(a.components))
+	started := 0
+	var cause error
+	for i, c := range a.components {
+		if err := c.Start(ctx); err != nil {
+			a.setState(i, StateFailed, err)
+			cause = fmt.Errorf("starting %s: %w", c.Name(), err)
+			break
+		}
+		a.setState(i, StateRunning, nil)
+		started++
+		if f, ok := c.(failer); ok {
+			go func(i int, c Component) {
+				if err, ok := <-f.Failed(); ok && err != nil {
+					a.setState(i, StateFailed, err)
+					failed <- fmt.Errorf("%s: %w", c.Name(), err)
+				}
+			}(i, c)
+		}
+	}
+
+	if cause == nil {
+		a.Logf("started")
+		select {
+		case <-ctx.Done():
+			a.Logf("shutting down")
+		case cause = <-failed:
+			a.Logf("shutting down: %v", cause)
+		}
+	}
+
+	a.mu.Lock()
+	a.stopping = true
+	a.mu.Unlock()
+	return errors.Join(cause, a.stop(started))
+}
+
+// stop stops the first n components in reverse order, sharing one deadline.
+// A component that does not stop in time does not keep the others from
+// being stopped.
+func (a *App) stop(n int) error {
+	ctx := context.Background()
+	if a.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.ShutdownTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for i := n - 1; i >= 0; i-- {
+		c := a.components[i]
+		begin := time.Now()
+		a.setState(i, StateStopping, nil)
+		if err := c.Stop(ctx); err != nil {
+			a.setState(i, StateFailed, err)
+			errs = append(errs, fmt.Errorf("stopping %s: %w", c.Name(), err))
+			continue
+		}
+		a.setState(i, StateStopped, nil)
+		a.Logf("stopped %s in %v", c.Name(), time.Since(begin).Round(time.Millisecond))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the start and stop calls of fake components.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+// fake returns a component that records its calls and fails to start with
+// startErr. A non-nil stop replaces the recorded no-op stop.
+func (r *recorder) fake(name string, startErr error, stop func(ctx context.Context) error) Component {
+	return Func(name,
+		func(context.Context) error {
+			r.add("start " + name)
+			return startErr
+		},
+		func(ctx context.Context) error {
+			r.add("stop " + name)
+			if stop != nil {
+				return stop(ctx)
+			}
+			return nil
+		},
+	)
+}
+
+func newTestApp(t *testing.T, timeout time.Duration) *App {
+	app := New(timeout)
+	app.Logf = t.Logf
+	return app
+}
+
+func states(app *App) []State {
+	var states []State
+	for _, s := range app.Status() {
+		states = append(states, s.State)
+	}
+	return states
+}
+
+func TestStopInReverseOrder(t *testing.T) {
+	var rec recorder
+	app := newTestApp(t, time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		app.Add(rec.fake(name, nil, nil))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := app.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if got := rec.list(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if got, want := states(app), []State{StateStopped, StateStopped, StateStopped}; !slices.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+	if !app.Stopping() {
+		t.Error("Stopping() = false after Run")
+	}
+}
+
+func TestWorkerFailureShutsDown(t *testing.T) {
+	var rec recorder
+	boom := errors.New("boom")
+	app := newTestApp(t, time.Second)
+	app.Add(rec.fake("database", nil, nil))
+	app.Add(Worker("relay", func(ctx context.Context) error { return boom }))
+	app.Add(Worker("scheduler", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}))
+
+	done := make(chan error, 1)
+	go func() { done <- app.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, boom) {
+			t.Fatalf("Run = %v, want the worker's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a failed worker did not shut the application down")
+	}
+	if got, want := rec.list(), []string{"start database", "stop database"}; !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	status := app.Status()
+	if status[1].Error != "boom" {
+		t.Errorf("relay error = %q, want boom", status[1].Error)
+	}
+	if got, want := states(app), []State{StateStopped, StateStopped, StateStopped}; !slices.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+}
+
+func TestStartFailureStopsStarted(t *testing.T) {
+	var rec recorder
+	refused := errors.New("address in use")
+	app := newTestApp(t, time.Second)
+	app.Add(rec.fake("a", nil, nil))
+	app.Add(rec.fake("b", nil, nil))
+	app.Add(rec.fake("c", refused, nil))
+	app.Add(rec.fake("d", nil, nil))
+
+	err := app.Run(context.Background())
+	if !errors.Is(err, refused) {
+		t.Fatalf("Run = %v, want the start error", err)
+	}
+	want := []string{"start a", "start b", "start c", "stop b", "stop a"}
+	if got := rec.list(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if got, want := states(app), []State{StateStopped, StateStopped, StateFailed, StatePending}; !slices.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+}
+
+func TestSharedShutdownDeadline(t *testing.T) {
+	var rec recorder
+	const timeout = 100 * time.Millisecond
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	app := newTestApp(t, timeout)
+	app.Add(rec.fake("a", nil, nil))
+	app.Add(rec.fake("b", nil, hang))
+	app.Add(rec.fake("c", nil, hang))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	begin := time.Now()
+	err := app.Run(ctx)
+	elapsed := time.Since(begin)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed < timeout || elapsed >= 2*timeout {
+		t.Errorf("shutdown took %v, want one deadline of %v for all components", elapsed, timeout)
+	}
+	want := []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if got := rec.list(); !slices.Equal(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if got, want := states(app), []State{StateStopped, StateFailed, StateFailed}; !slices.Equal(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Package logging sets up the structured logger and carries a per-request
+// logger, tagged with the request id, through request contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing to w in the given format at the given
+// minimum level (debug, info, warn or error).
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, use %s or %s", format, FormatText, FormatJSON)
+}
+
+type requestKey struct{}
+
+// request is the per-request state shared between Middleware and the code
+// further down the handler chain, which sees copies of the request.
+type request struct {
+	id     string
+	logger *slog.Logger
+	route  string
+	userID int
+}
+
+func fromContext(ctx context.Context) *request {
+	req, _ := ctx.Value(requestKey{}).(*request)
+	return req
+}
+
+// FromContext returns the request's logger, or the default logger outside
+// of requests.
+func FromContext(ctx context.Context) *slog.Logger {
+	if req := fromContext(ctx); req != nil {
+		return req.logger
+	}
+	return slog.Default()
+}
+
+// RequestID returns the id of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	if req := fromContext(ctx); req != nil {
+		return req.id
+	}
+	return ""
+}
+
+// SetUser records the authenticated user of the request, for the request
+// log line and every later message of the request's logger.
+func SetUser(ctx context.Context, userID int) {
+	if req := fromContext(ctx); req != nil {
+		req.userID = userID
+		req.logger = req.logger.With("user_id", userID)
+	}
+}
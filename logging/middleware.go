@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"ToDoProject/response"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	mux "github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the request id. An id sent by the client or a
+// proxy is kept, so one id follows a request through several services.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds accepted ids, which end up in every log line.
+const maxRequestIDLen = 128
+
+// Middleware assigns each request an id, puts a logger tagged with it into
+// the request context, and logs the request when it completes. It wraps the
+// router rather than being a mux middleware, so unmatched requests are
+// logged too.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			req := &request{id: id, logger: logger.With("request_id", id)}
+			rec := response.NewRecorder(w)
+			r = r.WithContext(context.WithValue(r.Context(), requestKey{}, req))
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			if rec.Status() >= 500 {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("request_id", id),
+				slog.String("method", r.Method),
+				slog.String("route", req.route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes", rec.Bytes()),
+			}
+			if req.userID != 0 {
+				attrs = append(attrs, slog.Int("user_id", req.userID))
+			}
+			// The base logger, since the request's logger may already
+			// carry the user id.
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// Route records the matched route on the request, for the request log
+// line. Register it with Router.Use: mux only exposes the route to handlers
+// below the router, not to Middleware wrapping it.
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req := fromContext(r.Context()); req != nil {
+			if current := mux.CurrentRoute(r); current != nil {
+				req.route, _ = current.GetPathTemplate()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,41 +1,199 @@
 package main
 
 import (
+	"ToDoProject/apiversion"
+	"ToDoProject/auth"
+	"ToDoProject/config"
 	_ "ToDoProject/docs"
+	"ToDoProject/events"
+	"ToDoProject/grpcserver"
 	"ToDoProject/handlers"
-	token "ToDoProject/jwttoken"
+	"ToDoProject/health"
+	"ToDoProject/jwttoken"
+	"ToDoProject/lifecycle"
+	"ToDoProject/logging"
+	"ToDoProject/metrics"
+	"ToDoProject/outbox"
+	"ToDoProject/ratelimit"
 	recovery "ToDoProject/safety"
 	"ToDoProject/store"
+	"ToDoProject/tracing"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	mux "github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// legacyDeprecation applies to the unversioned routes, which remain as
+// aliases of /v1 until their sunset.
+func legacyDeprecation(sunset time.Time) apiversion.Deprecation {
+	return apiversion.Deprecation{
+		Since:     time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:    sunset,
+		Successor: "/v1",
+	}
+}
+
+// @title ToDo API
+// @version 1.0
+// @BasePath /v1
 func main() {
-	connStr := recovery.GetDBConnStr()
+	cfg, err := config.Load("todo-api", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	// Also routes the standard log package through logger.
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore the default handling once shutdown has begun, so a
+		// second signal kills the process.
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := run(ctx, cfg, logger); err != nil {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and blocks until ctx is cancelled or a component
+// fails. Components stop in the reverse order they are added here: the
+// event streams end first so that the servers can drain, then the servers
+// stop accepting requests and finish the in-flight ones, then the
+// background workers stop, the database pool closes, and the remaining
+// spans are flushed last.
+func run(ctx context.Context, cfg *config.Config, logger *slog.Logger) error {
+	jwttoken.Configure(cfg.JWT)
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+
+	connStr := cfg.DB.ConnString()
 	todoStore, err := store.NewTodoStore(connStr)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("database: %w", err)
+	}
+	cfg.DB.ConfigurePool(todoStore.DB)
+	if err := metrics.RegisterDB(todoStore.DB, cfg.DB.Name); err != nil {
+		return fmt.Errorf("metrics: %w", err)
 	}
-	todoHandler := &handlers.TodoHandler{Store: todoStore}
+	hub := events.NewHub()
+	relay := outbox.NewRelay(todoStore)
+	relay.Retention = cfg.Outbox.Retention
+	relay.Register(outbox.Streams{Store: todoStore})
+	if cfg.Outbox.WebhookURL != "" {
+		relay.Register(outbox.NewWebhook(cfg.Outbox.WebhookURL, cfg.Outbox.WebhookSecret))
+	}
+	todoStore.Publisher = relay
+	todoStore.Observer = metrics.Store{}
+	jwttoken.UsePersonalTokens(todoStore)
+	jwttoken.UseAccountCheck(todoStore)
+	todoStore.Lockout = store.LockoutPolicy{
+		Threshold: cfg.Lockout.Threshold,
+		Delay:     cfg.Lockout.Delay,
+		Duration:  cfg.Lockout.Duration,
+	}
+	backend := rateLimitBackend(cfg.RateLimit, todoStore)
+	var limiter *ratelimit.Limiter
+	if backend != nil {
+		limiter = ratelimit.New(backend, cfg.RateLimit)
+	}
+	todoHandler := &handlers.TodoHandler{
+		Store:                todoStore,
+		Events:               hub,
+		BulkConfirmThreshold: cfg.API.BulkConfirmThreshold,
+		TrustForwardedFor:    cfg.RateLimit.TrustForwardedFor,
+		Limiter:              limiter,
+	}
+
+	app := lifecycle.New(cfg.ShutdownTimeout)
+	healthHandler := &health.Handler{Store: todoStore, App: app, Config: cfg, Started: time.Now()}
+	app.Add(lifecycle.Func("tracing", nil, shutdownTracing))
+	app.Add(lifecycle.Func("database", nil, func(context.Context) error {
+		return todoStore.DB.Close()
+	}))
+
+	app.Add(lifecycle.Worker("outbox relay", func(ctx context.Context) error {
+		relay.Run(ctx)
+		return nil
+	}))
+	app.Add(lifecycle.Worker("event listener", func(ctx context.Context) error {
+		return events.Listen(ctx, connStr, todoStore, hub)
+	}))
+	if backend != nil {
+		app.Add(lifecycle.Worker("rate limit sweeper", func(ctx context.Context) error {
+			ratelimit.Sweep(ctx, backend, time.Minute)
+			return nil
+		}))
+	}
+
+	app.Add(lifecycle.GRPCServer("grpc", fmt.Sprintf(":%d", cfg.GRPC.Port), grpcserver.New(todoStore, hub, limiter)))
+	app.Add(lifecycle.HTTPServer("http", &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.HTTP.Port),
+		Handler:           tracing.Middleware(logging.Middleware(logger)(router(cfg, todoHandler, healthHandler, limiter))),
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}))
 
+	app.Add(lifecycle.Func("event streams", nil, func(context.Context) error {
+		hub.Close()
+		return nil
+	}))
+
+	return app.Run(ctx)
+}
+
+// rateLimitBackend returns the configured rate limiter backend, or nil when
+// rate limiting is off.
+func rateLimitBackend(cfg config.RateLimit, todoStore *store.TodoStore) ratelimit.Backend {
+	switch cfg.Backend {
+	case "memory":
+		return ratelimit.NewMemory()
+	case "postgres":
+		return ratelimit.NewPostgres(todoStore.DB)
+	}
+	return nil
+}
+
+func router(cfg *config.Config, todoHandler *handlers.TodoHandler, healthHandler *health.Handler, limiter *ratelimit.Limiter) http.Handler {
 	r := mux.NewRouter()
-	r.Use(recovery.RecoverMiddleware)
+	r.Use(logging.Route, tracing.Route, metrics.Middleware, recovery.RecoverMiddleware)
 
-	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	r.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	r.Handle("/debug/info", jwttoken.AuthMiddleware(auth.Require(auth.Role(auth.RoleAdmin))(http.HandlerFunc(healthHandler.Info)))).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
-	r.HandleFunc("/login", todoHandler.LoginHandler).Methods("POST")
-	r.HandleFunc("/register", todoHandler.RegisterHandler).Methods("POST")
+	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-	api := r.PathPrefix("/todos").Subrouter()
-	api.Use(token.AuthMiddleware)
-	api.HandleFunc("", todoHandler.ListTodos).Methods("GET")
-	api.HandleFunc("", todoHandler.CreateTodo).Methods("POST")
-	api.HandleFunc("/{id}", todoHandler.PutTodo).Methods("PUT")
-	api.HandleFunc("/{id}", todoHandler.PatchTodo).Methods("PATCH")
-	api.HandleFunc("/{id}", todoHandler.DeleteTodo).Methods("DELETE")
+	apiversion.V1.Mount(r, routes(todoHandler, limiter))
 
-	port := recovery.GetPort()
-	http.ListenAndServe(":"+port, r)
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(legacyDeprecation(cfg.API.LegacySunset).Middleware)
+	routes(todoHandler, limiter)(legacy)
+	return r
 }
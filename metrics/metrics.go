@@ -0,0 +1,131 @@
+// Package metrics exports Prometheus metrics for the HTTP API, the database
+// pool, the store and a few business events. Metrics are kept in a registry
+// of their own, served by Handler.
+package metrics
+
+import (
+	models "ToDoProject/models"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	storeDuration = promauto.With(registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_store_operation_duration_seconds",
+		Help:    "Duration of store operations by method.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+
+	storeErrors = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_store_operation_errors_total",
+		Help: "Store operations that returned an error, including not found and conflict errors, by method.",
+	}, []string{"method"})
+
+	todosCreated = promauto.With(registry).NewCounter(prometheus.CounterOpts{
+		Name: "todo_todos_created_total",
+		Help: "Todos created.",
+	})
+
+	todosCompleted = promauto.With(registry).NewCounter(prometheus.CounterOpts{
+		Name: "todo_todos_completed_total",
+		Help: "Todos marked as done.",
+	})
+
+	logins = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_logins_total",
+		Help: "Login attempts by result, success or failure.",
+	}, []string{"result"})
+
+	deliveries = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_outbox_deliveries_total",
+		Help: "Outbox event deliveries to consumers such as webhooks, by consumer and result.",
+	}, []string{"consumer", "result"})
+
+	rateLimited = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_rate_limited_total",
+		Help: "Requests rejected by the rate limiter, by policy.",
+	}, []string{"policy"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// RegisterDB exports the connection pool statistics of db, such as open,
+// in-use and idle connections and wait counts, labelled with name.
+func RegisterDB(db *sql.DB, name string) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Store implements store.Observer.
+type Store struct{}
+
+func (Store) ObserveOperation(method string, duration time.Duration, err error) {
+	storeDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if err != nil {
+		storeErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (Store) ObserveChange(eventType string, old, new models.Todo) {
+	switch eventType {
+	case models.EventTodoCreated:
+		todosCreated.Inc()
+		if new.Done {
+			todosCompleted.Inc()
+		}
+	case models.EventTodoUpdated:
+		if new.Done && !old.Done {
+			todosCompleted.Inc()
+		}
+	}
+}
+
+// Login counts a login attempt.
+func Login(ok bool) {
+	logins.WithLabelValues(result(ok)).Inc()
+}
+
+// Delivery counts an attempt to deliver an outbox event to consumer.
+func Delivery(consumer string, err error) {
+	deliveries.WithLabelValues(consumer, result(err == nil)).Inc()
+}
+
+// RateLimited counts a request rejected under the named rate limit policy.
+func RateLimited(policy string) {
+	rateLimited.WithLabelValues(policy).Inc()
+}
+
+func result(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "failure"
+}
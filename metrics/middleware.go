@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"ToDoProject/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	mux "github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware counts and times requests by method, route template and
+// status. Register it with Router.Use so the route template is known;
+// labelling by template rather than path keeps the number of series
+// bounded. Requests that match no route are not counted.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		start := time.Now()
+		rec := response.NewRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(rec.Status())}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Auth event types, recorded for the account they concern.
+const (
+	AuthEventLoginSucceeded  = "login_succeeded"
+	AuthEventLoginFailed     = "login_failed"
+	AuthEventAccountLocked   = "account_locked"
+	AuthEventAccountUnlocked = "account_unlocked"
+)
+
+// AuthEvent is a sign-in related event of a user's account, such as a
+// failed login.
+type AuthEvent struct {
+	Type      string    `json:"type"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Client identifies where a request came from, for auth events.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// Scopes of personal access tokens. Access tokens from /login carry all of
+// them.
+const (
+	ScopeTodosRead  = "todos:read"
+	ScopeTodosWrite = "todos:write"
+	ScopeAccount    = "account"
+)
+
+// Scopes lists every scope.
+var Scopes = []string{ScopeTodosRead, ScopeTodosWrite, ScopeAccount}
+
+// PersonalTokenPrefix starts every personal access token, which tells them
+// apart from JWTs.
+const PersonalTokenPrefix = "todo_pat_"
+
+// PersonalToken describes a personal access token. The token itself is
+// only returned once, when it is created.
+type PersonalToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedPersonalToken is a new personal access token together with the
+// token itself.
+type CreatedPersonalToken struct {
+	PersonalToken
+	Token string `json:"token"`
+}
+
+// PersonalTokenRequest is the body of POST /account/tokens. A token without
+// ExpiresAt never expires.
+type PersonalTokenRequest struct {
+	Name      string     `json:"name" validate:"trim,notblank,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// BatchRequest is an ordered list of operations. When Atomic is unset or
+// true all operations run in one transaction and fail together; when false
+// each operation runs and fails on its own.
+type BatchRequest struct {
+	Atomic     *bool            `json:"atomic,omitempty"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation targets a todo either by ID or, for todos created earlier
+// in the same batch, by IDRef naming that operation's Ref.
+type BatchOperation struct {
+	Op    string                   `json:"op"`
+	Ref   string                   `json:"ref,omitempty"`
+	ID    int                      `json:"id,omitempty"`
+	IDRef string                   `json:"id_ref,omitempty"`
+	Data  TodoUpdateHandlerRequest `json:"data"`
+}
+
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Ref    string `json:"ref,omitempty"`
+	Status int    `json:"status"`
+	Todo   *Todo  `json:"todo,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
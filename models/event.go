@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	EventTodoCreated = "created"
+	EventTodoUpdated = "updated"
+	EventTodoDeleted = "deleted"
+)
+
+// TodoEvent is an outbox event. Seq is the user's change sequence number,
+// which orders a user's events by commit where ids do not.
+type TodoEvent struct {
+	ID        int64     `json:"id"`
+	Seq       int64     `json:"seq"`
+	UserId    int       `json:"userId"`
+	TodoId    int       `json:"todoId"`
+	Type      string    `json:"type"`
+	Payload   Todo      `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
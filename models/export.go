@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UserExportVersion is the format version written by exports. Imports reject
+// other versions.
+const UserExportVersion = 1
+
+// UserExport is everything stored for one user, as written by
+// "todoadmin export". PasswordHash is the bcrypt hash, so an imported user
+// keeps their password.
+type UserExport struct {
+	Version      int            `json:"version"`
+	ExportedAt   time.Time      `json:"exported_at"`
+	Username     string         `json:"username"`
+	PasswordHash string         `json:"password_hash"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Todos        []Todo         `json:"todos"`
+	History      []HistoryEntry `json:"history"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// HistoryEntry is one recorded change of a todo. Old is nil for creations
+// and New is nil for deletions.
+type HistoryEntry struct {
+	Seq       int64     `json:"seq"`
+	TodoId    int       `json:"todo_id"`
+	Type      string    `json:"type"`
+	Old       *Todo     `json:"old,omitempty"`
+	New       *Todo     `json:"new,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+// TodoChange is the latest server-side state of a todo that changed since a
+// sync token. Deleted changes are tombstones and carry no todo.
+type TodoChange struct {
+	TodoId  int   `json:"todo_id"`
+	Seq     int64 `json:"seq"`
+	Deleted bool  `json:"deleted"`
+	Todo    *Todo `json:"todo,omitempty"`
+}
+
+type SyncPullResponse struct {
+	Changes []TodoChange `json:"changes"`
+	Token   string       `json:"token"`
+	HasMore bool         `json:"has_more"`
+}
+
+// SyncClientChange is a change made on a client while offline. ClientId is
+// chosen by the client to match results to its local records, and makes
+// creates idempotent. BaseVersion is
+// the todo version the client last saw and is required for update and delete.
+type SyncClientChange struct {
+	ClientId        string                   `json:"client_id"`
+	Op              string                   `json:"op"`
+	TodoId          int                      `json:"todo_id,omitempty"`
+	BaseVersion     int                      `json:"base_version,omitempty"`
+	ClientTimestamp time.Time                `json:"client_timestamp"`
+	Fields          TodoUpdateHandlerRequest `json:"fields"`
+}
+
+type SyncPushRequest struct {
+	Changes []SyncClientChange `json:"changes"`
+}
+
+type SyncApplied struct {
+	ClientId string `json:"client_id"`
+	Op       string `json:"op"`
+	Todo     Todo   `json:"todo"`
+}
+
+const (
+	SyncConflictVersion  = "version_mismatch"
+	SyncConflictNotFound = "not_found"
+	SyncConflictInvalid  = "invalid"
+	// SyncConflictError reports a change that failed on the server. It
+	// may be pushed again.
+	SyncConflictError = "error"
+)
+
+// SyncConflict reports a client change that was not applied. Current is the
+// server's todo, or nil when the todo no longer exists.
+type SyncConflict struct {
+	ClientId string `json:"client_id"`
+	Reason   string `json:"reason"`
+	Detail   string `json:"detail,omitempty"`
+	Current  *Todo  `json:"current,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Applied   []SyncApplied  `json:"applied"`
+	Conflicts []SyncConflict `json:"conflicts"`
+}
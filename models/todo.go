@@ -9,38 +9,53 @@ type Todo struct {
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"created_at"`
 	Done        bool      `json:"done"`
+	Version     int       `json:"version"`
 }
 
 type TodoHandlerRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string `json:"title" validate:"trim,notblank,max=200"`
+	Description string `json:"description" validate:"max=2000"`
 }
 
 type TodoUpdateHandlerRequest struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
+	Title       *string `json:"title" validate:"trim,notblank,max=200"`
+	Description *string `json:"description" validate:"max=2000"`
 	Done        *bool   `json:"done"`
 }
 
 type TodoQueries struct {
-	Done        *bool
-	Timestamp   *string
-	Title       *string
-	Description *string
-	Order       *string
-	Sort        *string
-	Limit       *string
-	Offset      *string
+	Done          *bool
+	Timestamp     *string
+	CreatedBefore *string
+	CreatedAfter  *string
+	Title         *string
+	Description   *string
+	Order         *string
+	Sort          *string
+	Limit         *string
+	Offset        *string
+}
+
+// BulkResult reports how many todos a bulk update or delete matched and,
+// unless it was a dry run, the todos it changed.
+type BulkResult struct {
+	Matched int    `json:"matched"`
+	DryRun  bool   `json:"dry_run"`
+	Todos   []Todo `json:"todos,omitempty"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"trim,required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"trim,min=3,max=32,username"`
+	Password string `json:"password" validate:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 type User struct {
@@ -48,3 +63,16 @@ type User struct {
 	Username string
 	Password string
 }
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// GraphQLRequest is the body of POST /graphql.
+type GraphQLRequest struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"ToDoProject/metrics"
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often the relay deletes delivered events.
+const pruneInterval = time.Hour
+
+// Consumer receives outbox events. Name identifies the consumer's delivery
+// state in the database, so it must stay stable across restarts. Handle may
+// see an event again if the process dies before the delivery is committed,
+// so it should be idempotent on event.ID.
+type Consumer interface {
+	Name() string
+	Handle(ctx context.Context, event models.TodoEvent) error
+}
+
+// Relay polls the outbox and publishes each event to every registered
+// consumer once. It also implements store.EventPublisher: events committed
+// by this process wake it up, so they need not wait for the next poll.
+type Relay struct {
+	Store     *store.TodoStore
+	Interval  time.Duration
+	BatchSize int
+	// Retention is how long delivered events are kept, for clients that
+	// resume event streams by event id. Zero keeps them forever.
+	Retention time.Duration
+
+	mu        sync.Mutex
+	consumers []Consumer
+	wake      chan struct{}
+}
+
+func NewRelay(s *store.TodoStore) *Relay {
+	return &Relay{
+		Store:     s,
+		Interval:  time.Second,
+		BatchSize: 100,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func (r *Relay) Register(c Consumer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.consumers = append(r.consumers, c)
+}
+
+// Publish implements store.EventPublisher.
+func (r *Relay) Publish(models.TodoEvent) {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers pending events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		r.deliverAll(ctx)
+		if r.Retention > 0 && time.Since(lastPrune) >= pruneInterval {
+			r.prune(ctx)
+			lastPrune = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+func (r *Relay) registered() []Consumer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Consumer(nil), r.consumers...)
+}
+
+func (r *Relay) deliverAll(ctx context.Context) {
+	for _, c := range r.registered() {
+		for ctx.Err() == nil {
+			n, err := r.Store.DeliverOutbox(ctx, c.Name(), r.BatchSize, func(event models.TodoEvent) error {
+				err := c.Handle(ctx, event)
+				metrics.Delivery(c.Name(), err)
+				return err
+			})
+			if err != nil {
+				log.Printf("outbox: delivering to %s: %v", c.Name(), err)
+				break
+			}
+			if n < r.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// prune deletes the events older than the retention that every registered
+// consumer has been delivered.
+func (r *Relay) prune(ctx context.Context) {
+	var names []string
+	for _, c := range r.registered() {
+		names = append(names, c.Name())
+	}
+	n, err := r.Store.PruneOutbox(ctx, names, time.Now().Add(-r.Retention))
+	if err != nil {
+		log.Printf("outbox: pruning: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("outbox: pruned %d delivered events", n)
+	}
+}
@@ -0,0 +1,29 @@
+package outbox
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"context"
+	"time"
+)
+
+// streamsMaxAge is the age beyond which Streams no longer announces events.
+// Subscribers of live streams would not expect them, and clients that
+// resume by event id load them from the outbox anyway.
+const streamsMaxAge = 5 * time.Minute
+
+// Streams announces events on store.NotifyChannel, from where the event
+// listener of every replica hands them to its hub and so to the SSE,
+// WebSocket and gRPC streams.
+type Streams struct {
+	Store *store.TodoStore
+}
+
+func (Streams) Name() string { return "streams" }
+
+func (s Streams) Handle(ctx context.Context, event models.TodoEvent) error {
+	if time.Since(event.CreatedAt) > streamsMaxAge {
+		return nil
+	}
+	return s.Store.NotifyEvent(ctx, event.ID)
+}
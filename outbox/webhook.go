@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	models "ToDoProject/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook POSTs each event as JSON to URL. The body is signed with an
+// HMAC-SHA256 of Secret in the X-Todo-Signature header, as "sha256=<hex>",
+// and X-Todo-Event-ID carries the event id for receivers to deduplicate.
+// Any status other than 2xx is retried on the next poll.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// NewWebhook returns a webhook consumer with a 10 second timeout.
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (*Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Handle(ctx context.Context, event models.TodoEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Todo-Event-ID", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Todo-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
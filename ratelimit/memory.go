@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in the process. Each replica counts on its own, so
+// with n replicas behind a load balancer a client gets up to n times the
+// allowance.
+type Memory struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+	now  func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{tats: make(map[string]time.Time), now: time.Now}
+}
+
+func (m *Memory) Take(_ context.Context, key string, p Policy) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	tat := m.tats[key]
+	next := advance(p, tat, now)
+	if !allows(p, next, now) {
+		return result(p, tat, now, false), nil
+	}
+	m.tats[key] = next
+	return result(p, next, now, true), nil
+}
+
+func (m *Memory) Sweep(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for key, tat := range m.tats {
+		if !tat.After(now) {
+			delete(m.tats, key)
+		}
+	}
+	return nil
+}
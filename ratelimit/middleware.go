@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"ToDoProject/auth"
+	"ToDoProject/config"
+	"ToDoProject/decode"
+	"ToDoProject/logging"
+	"ToDoProject/metrics"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limiter applies the configured policies to HTTP requests. A nil Limiter,
+// or one without a Backend, lets everything through.
+type Limiter struct {
+	Backend Backend
+
+	AuthPerIP       Policy
+	AuthPerUsername Policy
+	APIPerUser      Policy
+
+	// TrustForwardedFor is config.RateLimit.TrustForwardedFor.
+	TrustForwardedFor bool
+}
+
+// New returns a Limiter for cfg, storing its buckets in backend.
+func New(backend Backend, cfg config.RateLimit) *Limiter {
+	return &Limiter{
+		Backend:           backend,
+		AuthPerIP:         PolicyFor("auth_ip", cfg.AuthPerIP),
+		AuthPerUsername:   PolicyFor("auth_username", cfg.AuthPerUsername),
+		APIPerUser:        PolicyFor("api_user", cfg.APIPerUser),
+		TrustForwardedFor: cfg.TrustForwardedFor,
+	}
+}
+
+// Auth limits the authentication endpoints per client address and, when
+// the body names a user, per username, so that guessing one account's
+// password from many addresses is limited too.
+func (l *Limiter) Auth(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := []limit{{l.AuthPerIP, ClientIP(r, l.TrustForwardedFor)}}
+		if username := peekUsername(r); username != "" {
+			limits = append(limits, limit{l.AuthPerUsername, hashUsername(username)})
+		}
+		if l.allow(w, r, limits...) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// User limits the authenticated API per user. It must run after
+// jwttoken.AuthMiddleware, which puts the caller's principal in the context.
+func (l *Limiter) User(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.FromContext(r.Context())
+		if l.allow(w, r, limit{l.APIPerUser, strconv.Itoa(p.UserID)}) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// AllowUser takes from the per-user API bucket for requests that do not
+// pass through User, such as WebSocket messages and gRPC calls. When the
+// bucket is empty it returns false and how long to wait.
+func (l *Limiter) AllowUser(ctx context.Context, userID int) (retryAfter time.Duration, ok bool) {
+	if l == nil || l.Backend == nil {
+		return 0, true
+	}
+	policy, res, found := l.take(ctx, limit{l.APIPerUser, strconv.Itoa(userID)})
+	if !found || res.Allowed {
+		return 0, true
+	}
+	metrics.RateLimited(policy.Name)
+	return res.RetryAfter, false
+}
+
+type limit struct {
+	policy Policy
+	key    string
+}
+
+// take takes from each bucket in turn and returns the policy and result of
+// the most exhausted one, stopping at the first bucket that is empty. found
+// is false when no bucket was consulted. Backend failures let the request
+// through: an unavailable limiter should not take the API down with it.
+func (l *Limiter) take(ctx context.Context, limits ...limit) (tightest Policy, res Result, found bool) {
+	for _, lim := range limits {
+		if !lim.policy.enabled() {
+			continue
+		}
+		got, err := l.Backend.Take(ctx, lim.policy.Name+":"+lim.key, lim.policy)
+		if err != nil {
+			logging.FromContext(ctx).Error("rate limiter failed", "policy", lim.policy.Name, "error", err)
+			continue
+		}
+		if !found || !got.Allowed || got.Remaining < res.Remaining {
+			tightest, res, found = lim.policy, got, true
+		}
+		if !got.Allowed {
+			break
+		}
+	}
+	return tightest, res, found
+}
+
+// allow writes the RateLimit headers of the most exhausted bucket. It
+// answers 429 and returns false when a bucket is empty.
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, limits ...limit) bool {
+	if l.Backend == nil {
+		return true
+	}
+	tightest, res, found := l.take(r.Context(), limits...)
+	if !found {
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", tightest.Limit, seconds(tightest.Period)))
+	if res.Allowed {
+		return true
+	}
+
+	metrics.RateLimited(tightest.Name)
+	h.Set("Retry-After", seconds(res.RetryAfter))
+	decode.ProblemResponse(w, r, decode.Problem{
+		Type:   decode.ProblemTypeRateLimited,
+		Title:  "Rate limit exceeded",
+		Status: http.StatusTooManyRequests,
+		Detail: fmt.Sprintf("too many requests, retry in %s seconds", seconds(res.RetryAfter)),
+	})
+	return false
+}
+
+// seconds rounds d up to whole seconds, as the headers require.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ClientIP returns the address of the client that sent r. With
+// trustForwardedFor it is the last X-Forwarded-For entry, if there is one;
+// see config.RateLimit.TrustForwardedFor.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// peekUsername returns the username field of a JSON body, leaving the body
+// to be read again by the handler.
+func peekUsername(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, decode.MaxBodyBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+	var req struct {
+		Username string `json:"username"`
+	}
+	json.Unmarshal(body, &req)
+	return req.Username
+}
+
+// hashUsername keys buckets by a digest of the username, so that the
+// Postgres backend does not store attempted usernames in the clear.
+func hashUsername(username string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(username))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Postgres keeps buckets in the rate_limits table, so that all replicas
+// share them. Each take is one statement that only updates the row when
+// the request is allowed; the row lock serialises concurrent takes of a key.
+type Postgres struct {
+	DB *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{DB: db}
+}
+
+func (pg *Postgres) Take(ctx context.Context, key string, p Policy) (Result, error) {
+	interval := p.interval().Microseconds()
+	period := p.Period.Microseconds()
+
+	var tat, now time.Time
+	err := pg.DB.QueryRowContext(ctx,
+		`INSERT INTO rate_limits AS r (key, tat) VALUES ($1, now() + $2 * interval '1 microsecond')
+		ON CONFLICT (key) DO UPDATE SET tat = GREATEST(r.tat, now()) + $2 * interval '1 microsecond'
+		WHERE GREATEST(r.tat, now()) + $2 * interval '1 microsecond' <= now() + $3 * interval '1 microsecond'
+		RETURNING tat, now()`,
+		key, interval, period,
+	).Scan(&tat, &now)
+	if err == nil {
+		return result(p, tat, now, true), nil
+	}
+	if err != sql.ErrNoRows {
+		return Result{}, err
+	}
+
+	// The WHERE clause kept the row as it was: the request is denied.
+	err = pg.DB.QueryRowContext(ctx, "SELECT tat, now() FROM rate_limits WHERE key=$1", key).Scan(&tat, &now)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(p, tat, now, false), nil
+}
+
+func (pg *Postgres) Sweep(ctx context.Context) error {
+	_, err := pg.DB.ExecContext(ctx, "DELETE FROM rate_limits WHERE tat <= now()")
+	return err
+}
@@ -0,0 +1,102 @@
+// Package ratelimit limits request rates with token buckets, kept in memory
+// or, to share them between replicas, in PostgreSQL.
+//
+// Buckets are implemented with the generic cell rate algorithm: instead of
+// a token count and a timestamp, each key stores the theoretical arrival
+// time (TAT) at which its bucket will be full again. A request is allowed
+// when, after adding one emission interval, the TAT is at most one period
+// ahead of now. That makes a take a single compare-and-set, in memory and
+// in one SQL statement alike.
+package ratelimit
+
+import (
+	"ToDoProject/config"
+	"context"
+	"log"
+	"time"
+)
+
+// Policy allows Limit requests per Period for each key, in bursts of up to
+// Limit. The zero Policy allows everything.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// PolicyFor returns the named policy for a configured rate.
+func PolicyFor(name string, r config.Rate) Policy {
+	return Policy{Name: name, Limit: r.Requests, Period: r.Per}
+}
+
+func (p Policy) enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+// interval is the time one request's token takes to refill.
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Limit)
+}
+
+// Result is the outcome of a take. Reset is how long until the bucket is
+// full again; RetryAfter, for denied requests, how long until the next
+// request would be allowed.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Backend stores buckets.
+type Backend interface {
+	// Take spends one request of key's allowance under p, if there is
+	// one left.
+	Take(ctx context.Context, key string, p Policy) (Result, error)
+	// Sweep forgets the buckets that are full again, which are the same
+	// as no bucket at all.
+	Sweep(ctx context.Context) error
+}
+
+// advance returns the TAT after one more request, given the stored TAT.
+func advance(p Policy, tat, now time.Time) time.Time {
+	if tat.Before(now) {
+		tat = now
+	}
+	return tat.Add(p.interval())
+}
+
+// allows reports whether a request moving the TAT to next is within the
+// allowance.
+func allows(p Policy, next, now time.Time) bool {
+	return next.Sub(now) <= p.Period
+}
+
+// result describes the bucket with the given TAT. For allowed requests tat
+// is the new TAT, for denied ones the unchanged stored one.
+func result(p Policy, tat, now time.Time, allowed bool) Result {
+	r := Result{Allowed: allowed, Reset: max(tat.Sub(now), 0)}
+	if allowed {
+		r.Remaining = int((p.Period - r.Reset) / p.interval())
+	} else {
+		r.RetryAfter = advance(p, tat, now).Sub(now) - p.Period
+	}
+	return r
+}
+
+// Sweep sweeps backend every interval until ctx is cancelled. Failures are
+// logged; the next sweep catches up.
+func Sweep(ctx context.Context, backend Backend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := backend.Sweep(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("ratelimit: sweep: %v", err)
+			}
+		}
+	}
+}
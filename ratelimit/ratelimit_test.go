@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"ToDoProject/decode"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a fake time source for the memory backend.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestMemory() (*Memory, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewMemory()
+	m.now = c.now
+	return m, c
+}
+
+// take is one call to Take, made after moving the clock on by wait.
+type take struct {
+	wait time.Duration
+	key  string
+	want Result
+}
+
+func TestMemoryTake(t *testing.T) {
+	// Three requests per three seconds: one token refills every second.
+	policy := Policy{Name: "test", Limit: 3, Period: 3 * time.Second}
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "allows a full bucket",
+			takes: []take{
+				{0, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+			},
+		},
+		{
+			name: "denies an empty bucket",
+			takes: []take{
+				{0, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{0, "a", Result{Allowed: false, Reset: 3 * time.Second, RetryAfter: time.Second}},
+				{0, "a", Result{Allowed: false, Reset: 3 * time.Second, RetryAfter: time.Second}},
+			},
+		},
+		{
+			name: "retry after counts down",
+			takes: []take{
+				{0, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{400 * time.Millisecond, "a", Result{Allowed: false, Reset: 2600 * time.Millisecond, RetryAfter: 600 * time.Millisecond}},
+				{500 * time.Millisecond, "a", Result{Allowed: false, Reset: 2100 * time.Millisecond, RetryAfter: 100 * time.Millisecond}},
+			},
+		},
+		{
+			name: "refills one token per interval",
+			takes: []take{
+				{0, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{time.Second, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{0, "a", Result{Allowed: false, Reset: 3 * time.Second, RetryAfter: time.Second}},
+			},
+		},
+		{
+			name: "refills completely after a period",
+			takes: []take{
+				{0, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{time.Hour, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+			},
+		},
+		{
+			name: "keeps keys apart",
+			takes: []take{
+				{0, "a", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}},
+				{0, "a", Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}},
+				{0, "b", Result{Allowed: true, Remaining: 2, Reset: time.Second}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newTestMemory()
+			for i, tk := range tt.takes {
+				c.t = c.t.Add(tk.wait)
+				got, err := m.Take(context.Background(), tk.key, policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tk.want {
+					t.Errorf("take %d = %+v, want %+v", i, got, tk.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 2, Period: 2 * time.Second}
+	m, c := newTestMemory()
+	m.Take(context.Background(), "full", policy)
+	c.t = c.t.Add(time.Second)
+	m.Take(context.Background(), "draining", policy)
+
+	if err := m.Sweep(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.tats["full"]; ok {
+		t.Error("the bucket that is full again was kept")
+	}
+	if _, ok := m.tats["draining"]; !ok {
+		t.Error("the bucket still refilling was swept")
+	}
+}
+
+func TestPeekUsername(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"login", `{"username":"alice","password":"secret"}`, "alice"},
+		{"no username", `{"refresh_token":"abc"}`, ""},
+		{"invalid JSON", `{"username":`, ""},
+		{"empty", ``, ""},
+		{"larger than the limit", `{"username":"bob","padding":"` + strings.Repeat("x", decode.MaxBodyBytes) + `"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/login", strings.NewReader(tt.body))
+			if got := peekUsername(r); got != tt.want {
+				t.Errorf("peekUsername = %q, want %q", got, tt.want)
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body read after peeking has %d bytes, want the %d sent", len(body), len(tt.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"ToDoProject/auth"
+	"ToDoProject/handlers"
+	token "ToDoProject/jwttoken"
+	models "ToDoProject/models"
+	"ToDoProject/ratelimit"
+	"net/http"
+
+	mux "github.com/gorilla/mux"
+)
+
+// endpoint is an authenticated route and the requirement its caller must
+// meet, such as a token scope. Without one, any authenticated caller may
+// use it.
+type endpoint struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+	require auth.Requirement
+}
+
+// handle registers the endpoints on r, each behind its requirement.
+func handle(r *mux.Router, endpoints ...endpoint) {
+	for _, e := range endpoints {
+		var h http.Handler = e.handler
+		if e.require != nil {
+			h = auth.Require(e.require)(h)
+		}
+		r.Handle(e.path, h).Methods(e.method)
+	}
+}
+
+var (
+	readTodos  = auth.Scope(models.ScopeTodosRead)
+	writeTodos = auth.Scope(models.ScopeTodosWrite)
+	account    = auth.Scope(models.ScopeAccount)
+)
+
+// routes returns the API routes. They are registered once per mounted
+// version and once more for the deprecated unversioned paths.
+func routes(todoHandler *handlers.TodoHandler, limiter *ratelimit.Limiter) func(r *mux.Router) {
+	return func(r *mux.Router) {
+		r.Handle("/login", limiter.Auth(http.HandlerFunc(todoHandler.LoginHandler))).Methods("POST")
+		r.Handle("/register", limiter.Auth(http.HandlerFunc(todoHandler.RegisterHandler))).Methods("POST")
+		r.Handle("/refresh", limiter.Auth(http.HandlerFunc(todoHandler.RefreshHandler))).Methods("POST")
+		r.HandleFunc("/ws", todoHandler.ServeWS).Methods("GET")
+
+		api := r.PathPrefix("/todos").Subrouter()
+		api.Use(token.AuthMiddleware, limiter.User)
+		handle(api,
+			endpoint{"GET", "", todoHandler.ListTodos, readTodos},
+			endpoint{"POST", "", todoHandler.CreateTodo, writeTodos},
+			endpoint{"PATCH", "", todoHandler.BulkUpdateTodos, writeTodos},
+			endpoint{"DELETE", "", todoHandler.BulkDeleteTodos, writeTodos},
+			endpoint{"GET", "/events", todoHandler.StreamTodoEvents, readTodos},
+			endpoint{"POST", "/batch", todoHandler.BatchTodos, writeTodos},
+			endpoint{"GET", "/{id}", todoHandler.GetTodo, readTodos},
+			endpoint{"PUT", "/{id}", todoHandler.PutTodo, writeTodos},
+			endpoint{"PATCH", "/{id}", todoHandler.PatchTodo, writeTodos},
+			endpoint{"DELETE", "/{id}", todoHandler.DeleteTodo, writeTodos},
+		)
+
+		syncAPI := r.PathPrefix("/sync").Subrouter()
+		syncAPI.Use(token.AuthMiddleware, limiter.User)
+		handle(syncAPI,
+			endpoint{"GET", "", todoHandler.PullChanges, readTodos},
+			endpoint{"POST", "", todoHandler.PushChanges, writeTodos},
+		)
+
+		accountAPI := r.PathPrefix("/account").Subrouter()
+		accountAPI.Use(token.AuthMiddleware, limiter.User)
+		handle(accountAPI,
+			endpoint{"GET", "/auth-events", todoHandler.AuthEvents, account},
+			endpoint{"GET", "/tokens", todoHandler.ListPersonalTokens, account},
+			endpoint{"POST", "/tokens", todoHandler.CreatePersonalToken, account},
+			endpoint{"DELETE", "/tokens/{id}", todoHandler.RevokePersonalToken, account},
+		)
+
+		graphqlAPI := r.PathPrefix("/graphql").Subrouter()
+		graphqlAPI.Use(token.AuthMiddleware, limiter.User)
+		handle(graphqlAPI,
+			// Mutations also check for todos:write.
+			endpoint{"POST", "", todoHandler.GraphQL(), readTodos},
+		)
+	}
+}
@@ -2,15 +2,18 @@ package safety
 
 import (
 	"ToDoProject/decode"
+	"ToDoProject/logging"
 	"fmt"
 	"net/http"
+	"runtime/debug"
 )
 
 func RecoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				decode.JSONError(w, fmt.Errorf("internal server error: %v", err), http.StatusInternalServerError)
+				logging.FromContext(r.Context()).Error("panic", "panic", err, "stack", string(debug.Stack()))
+				decode.JSONError(w, r, fmt.Errorf("internal server error"), http.StatusInternalServerError)
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -0,0 +1,357 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The operations in this file are meant for operators; see cmd/todoadmin.
+// They bypass the outbox, so clients are not notified of their effects.
+
+// FindUser looks a user up by name.
+func (s *TodoStore) FindUser(ctx context.Context, username string) (_ models.User, err error) {
+	ctx, op := s.begin(ctx, "FindUser")
+	defer op.end(&err)
+	var u models.User
+	err = s.conn(ctx).QueryRow(
+		"SELECT id, username, password FROM users WHERE username=$1",
+		username,
+	).Scan(&u.ID, &u.Username, &u.Password)
+	if err == sql.ErrNoRows {
+		return models.User{}, &Error{Kind: ErrNotFound, Message: fmt.Sprintf("user %q not found", username), Err: err}
+	}
+	return u, err
+}
+
+// CheckUserActive fails with ErrUnauthorized when the user has been
+// disabled and with ErrNotFound when it does not exist.
+func (s *TodoStore) CheckUserActive(ctx context.Context, id int) (err error) {
+	ctx, op := s.begin(ctx, "CheckUserActive")
+	defer op.end(&err)
+	var disabled bool
+	err = s.conn(ctx).QueryRow("SELECT disabled_at IS NOT NULL FROM users WHERE id=$1", id).Scan(&disabled)
+	if err == sql.ErrNoRows {
+		return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("user %d not found", id), Err: err}
+	}
+	if err != nil {
+		return err
+	}
+	if disabled {
+		return Unauthorized("user disabled")
+	}
+	return nil
+}
+
+// ActiveUserRoles returns the roles currently granted to a user. Like
+// CheckUserActive it fails with ErrUnauthorized when the user has been
+// disabled and with ErrNotFound when it does not exist.
+func (s *TodoStore) ActiveUserRoles(ctx context.Context, id int) (_ []string, err error) {
+	ctx, op := s.begin(ctx, "ActiveUserRoles")
+	defer op.end(&err)
+	var disabled bool
+	var roles []string
+	err = s.conn(ctx).QueryRow("SELECT disabled_at IS NOT NULL, roles FROM users WHERE id=$1", id).Scan(&disabled, pq.Array(&roles))
+	if err == sql.ErrNoRows {
+		return nil, &Error{Kind: ErrNotFound, Message: fmt.Sprintf("user %d not found", id), Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		return nil, Unauthorized("user disabled")
+	}
+	return roles, nil
+}
+
+// SetUserDisabled disables or re-enables a user. Disabled users cannot log
+// in or refresh their tokens.
+func (s *TodoStore) SetUserDisabled(ctx context.Context, id int, disabled bool) (err error) {
+	ctx, op := s.begin(ctx, "SetUserDisabled")
+	defer op.end(&err)
+	res, err := s.conn(ctx).Exec(
+		"UPDATE users SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) END WHERE id=$1",
+		id, disabled,
+	)
+	if err != nil {
+		return err
+	}
+	return userAffected(id, res)
+}
+
+// ResetPassword replaces a user's password.
+func (s *TodoStore) ResetPassword(ctx context.Context, id int, password string) (err error) {
+	ctx, op := s.begin(ctx, "ResetPassword")
+	defer op.end(&err)
+	hashed, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	res, err := s.conn(ctx).Exec("UPDATE users SET password=$2 WHERE id=$1", id, hashed)
+	if err != nil {
+		return err
+	}
+	return userAffected(id, res)
+}
+
+// SetUserRole grants a role to a user or, with granted false, takes it
+// away. Access tokens are checked against the stored roles on every
+// request, so the change applies to them at once.
+func (s *TodoStore) SetUserRole(ctx context.Context, id int, role string, granted bool) (err error) {
+	ctx, op := s.begin(ctx, "SetUserRole")
+	defer op.end(&err)
+	res, err := s.conn(ctx).Exec(
+		`UPDATE users SET roles = CASE WHEN $3 THEN array_append(array_remove(roles, $2), $2) ELSE array_remove(roles, $2) END
+		WHERE id=$1`,
+		id, role, granted,
+	)
+	if err != nil {
+		return err
+	}
+	return userAffected(id, res)
+}
+
+func userAffected(id int, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return NotFound("user %d not found", id)
+	}
+	return nil
+}
+
+// PurgeHistory deletes history rows recorded before the cutoff and returns
+// how many there were. With dryRun the rows are only counted.
+//
+// Sync clients whose cursor predates the cutoff miss the purged changes and
+// must sync again from scratch.
+func (s *TodoStore) PurgeHistory(ctx context.Context, before time.Time, dryRun bool) (_ int64, err error) {
+	ctx, op := s.begin(ctx, "PurgeHistory")
+	defer op.end(&err)
+	return s.purge(ctx, dryRun, "DELETE FROM todo_history WHERE created_at < $1", before)
+}
+
+// PurgeTrash deletes what is left of todos deleted before the cutoff: all
+// history rows of todos that no longer exist and whose deletion is older
+// than the cutoff. With dryRun the rows are only counted.
+func (s *TodoStore) PurgeTrash(ctx context.Context, before time.Time, dryRun bool) (_ int64, err error) {
+	ctx, op := s.begin(ctx, "PurgeTrash")
+	defer op.end(&err)
+	return s.purge(ctx, dryRun,
+		`DELETE FROM todo_history WHERE todo_id IN (
+			SELECT todo_id FROM todo_history WHERE change_type=$2 AND created_at < $1
+		) AND NOT EXISTS (SELECT 1 FROM todos WHERE todos.id = todo_history.todo_id)`,
+		before, models.EventTodoDeleted,
+	)
+}
+
+func (s *TodoStore) purge(ctx context.Context, dryRun bool, query string, args ...interface{}) (int64, error) {
+	sqlTx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer sqlTx.Rollback()
+
+	res, err := conn{ctx: ctx, c: sqlTx}.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return n, nil
+	}
+	return n, sqlTx.Commit()
+}
+
+// ExportUser reads a user with all of their todos and history.
+func (s *TodoStore) ExportUser(ctx context.Context, username string) (_ models.UserExport, err error) {
+	ctx, op := s.begin(ctx, "ExportUser")
+	defer op.end(&err)
+	e := models.UserExport{Version: models.UserExportVersion, ExportedAt: time.Now().UTC()}
+	var userID int
+	err = s.conn(ctx).QueryRow(
+		"SELECT id, username, password, created_at FROM users WHERE username=$1",
+		username,
+	).Scan(&userID, &e.Username, &e.PasswordHash, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return e, &Error{Kind: ErrNotFound, Message: fmt.Sprintf("user %q not found", username), Err: err}
+	}
+	if err != nil {
+		return e, err
+	}
+
+	if e.Todos, err = s.List(ctx, userID); err != nil {
+		return e, err
+	}
+
+	rows, err := s.conn(ctx).Query(
+		"SELECT COALESCE(seq, 0), todo_id, change_type, old_value, new_value, created_at FROM todo_history WHERE user_id=$1 ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return e, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h models.HistoryEntry
+		var changeType, oldValue, newValue sql.NullString
+		if err := rows.Scan(&h.Seq, &h.TodoId, &changeType, &oldValue, &newValue, &h.CreatedAt); err != nil {
+			return e, err
+		}
+		h.Type = changeType.String
+		if h.Type != models.EventTodoCreated {
+			h.Old = historyValue(oldValue)
+		}
+		if h.Type != models.EventTodoDeleted {
+			h.New = historyValue(newValue)
+		}
+		e.History = append(e.History, h)
+	}
+	return e, rows.Err()
+}
+
+// ImportUser creates the exported user under username, or under the
+// exported name when username is empty, and returns the new user id. Todos
+// get new ids; history keeps its change sequence numbers. It fails with
+// ErrConflict when the name is taken.
+func (s *TodoStore) ImportUser(ctx context.Context, e models.UserExport, username string) (_ int, err error) {
+	ctx, op := s.begin(ctx, "ImportUser")
+	defer op.end(&err)
+	if e.Version != models.UserExportVersion {
+		return 0, Validation(fmt.Sprintf("unsupported export version %d", e.Version))
+	}
+	if username == "" {
+		username = e.Username
+	}
+
+	var userID int
+	err = s.withTx(ctx, func(t *tx) error {
+		var taken bool
+		if err := t.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username=$1)", username).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return Conflict("username %q already exists", username)
+		}
+		err := t.QueryRow(
+			"INSERT INTO users(username, password, created_at) VALUES($1, $2, $3) RETURNING id",
+			username, e.PasswordHash, e.CreatedAt,
+		).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		ids := make(map[int]int)
+		for _, todo := range e.Todos {
+			var id int
+			err := t.QueryRow(
+				"INSERT INTO todos(user_id, title, description, done, version, created_at) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+				userID, todo.Title, todo.Description, todo.Done, todo.Version, todo.CreatedAt,
+			).Scan(&id)
+			if err != nil {
+				return err
+			}
+			ids[todo.ID] = id
+		}
+
+		var lastSeq int64
+		for _, h := range e.History {
+			id, ok := ids[h.TodoId]
+			if !ok {
+				// The todo was deleted; reserve an id so its history
+				// cannot be mistaken for a future todo's.
+				if err := t.QueryRow("SELECT nextval(pg_get_serial_sequence('todos', 'id'))").Scan(&id); err != nil {
+					return err
+				}
+				ids[h.TodoId] = id
+			}
+			oldValue, err := importedValue(h.Old, id, userID)
+			if err != nil {
+				return err
+			}
+			newValue, err := importedValue(h.New, id, userID)
+			if err != nil {
+				return err
+			}
+			_, err = t.Exec(
+				"INSERT INTO todo_history(todo_id, user_id, seq, change_type, old_value, new_value, created_at) VALUES($1, $2, $3, $4, $5, $6, $7)",
+				id, userID, h.Seq, h.Type, oldValue, newValue, h.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			lastSeq = max(lastSeq, h.Seq)
+		}
+
+		if lastSeq > 0 {
+			_, err := t.Exec("INSERT INTO user_change_seq(user_id, last_seq) VALUES($1, $2)", userID, lastSeq)
+			return err
+		}
+		return nil
+	})
+	return userID, err
+}
+
+// importedValue re-encodes a history snapshot with the todo's new ids. A
+// missing snapshot is stored as an empty todo, as recordHistory does.
+func importedValue(t *models.Todo, todoID, userID int) (string, error) {
+	var v models.Todo
+	if t != nil {
+		v = *t
+		v.ID, v.UserId = todoID, userID
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// TableStats describes one table of the database.
+type TableStats struct {
+	Name  string
+	Rows  int64
+	Bytes int64
+}
+
+// Stats returns the exact row count and the total size on disk, including
+// indexes and TOAST, of every table in the current schema.
+func (s *TodoStore) Stats(ctx context.Context) (_ []TableStats, err error) {
+	ctx, op := s.begin(ctx, "Stats")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query(
+		`SELECT c.relname, pg_total_relation_size(c.oid) FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'r' AND n.nspname = current_schema() ORDER BY c.relname`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var stats []TableStats
+	for rows.Next() {
+		var t TableStats
+		if err := rows.Scan(&t.Name, &t.Bytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats = append(stats, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range stats {
+		if err := s.conn(ctx).QueryRow("SELECT count(*) FROM " + pq.QuoteIdentifier(stats[i].Name)).Scan(&stats[i].Rows); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+
+	_ "github.com/lib/pq"
+)
+
+// Batch exposes the todo mutations of TodoStore bound to one transaction.
+// It is only valid inside the function passed to TodoStore.Batch.
+type Batch struct {
+	s  *TodoStore
+	tx *tx
+}
+
+// Batch runs fn with a Batch whose mutations share a single transaction.
+// The transaction commits if fn returns nil and is rolled back otherwise;
+// events are published only after the commit.
+func (s *TodoStore) Batch(ctx context.Context, fn func(b *Batch) error) (err error) {
+	ctx, op := s.begin(ctx, "Batch")
+	defer op.end(&err)
+	return s.withTx(ctx, func(tx *tx) error {
+		return fn(&Batch{s: s, tx: tx})
+	})
+}
+
+// The methods below take a context only to match their TodoStore
+// counterparts: they run in the batch's transaction, whose statements are
+// traced under the span of TodoStore.Batch.
+
+func (b *Batch) Create(_ context.Context, userId int, title string, description string, done bool) (models.Todo, error) {
+	return b.s.create(b.tx, userId, title, description, done)
+}
+
+func (b *Batch) SoftUpdate(_ context.Context, userId int, id int, model models.TodoUpdateHandlerRequest) (models.Todo, error) {
+	return b.s.update(b.tx, userId, id, nil, applyFields(model))
+}
+
+func (b *Batch) HardUpdate(_ context.Context, userId int, id int, model models.TodoUpdateHandlerRequest) (models.Todo, error) {
+	return b.s.update(b.tx, userId, id, nil, replaceFields(model))
+}
+
+func (b *Batch) Delete(_ context.Context, userId int, id int) (models.Todo, error) {
+	return b.s.remove(b.tx, userId, id, nil)
+}
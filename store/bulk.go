@@ -0,0 +1,90 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+
+	_ "github.com/lib/pq"
+)
+
+// BulkUpdate applies the non-nil fields of model to every todo of the user
+// matching the filter fields of m; ordering and pagination are ignored. The
+// matching rows are locked and counted first and check is called with the
+// count; if it returns an error nothing is written and that error is
+// returned. Each updated todo gets its own history row and event.
+func (s *TodoStore) BulkUpdate(ctx context.Context, userId int, m models.TodoQueries, model models.TodoUpdateHandlerRequest, check func(matched int) error) (_ []models.Todo, err error) {
+	ctx, op := s.begin(ctx, "BulkUpdate")
+	defer op.end(&err)
+	var todos []models.Todo
+	err = s.withTx(ctx, func(tx *tx) error {
+		ids, err := s.lockMatching(tx, userId, m)
+		if err != nil {
+			return err
+		}
+		if err := check(len(ids)); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			t, err := s.update(tx, userId, id, nil, applyFields(model))
+			if err != nil {
+				return err
+			}
+			todos = append(todos, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// BulkDelete deletes every todo of the user matching the filter fields of m,
+// with the same check semantics as BulkUpdate.
+func (s *TodoStore) BulkDelete(ctx context.Context, userId int, m models.TodoQueries, check func(matched int) error) (_ []models.Todo, err error) {
+	ctx, op := s.begin(ctx, "BulkDelete")
+	defer op.end(&err)
+	var todos []models.Todo
+	err = s.withTx(ctx, func(tx *tx) error {
+		ids, err := s.lockMatching(tx, userId, m)
+		if err != nil {
+			return err
+		}
+		if err := check(len(ids)); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			t, err := s.remove(tx, userId, id, nil)
+			if err != nil {
+				return err
+			}
+			todos = append(todos, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (s *TodoStore) lockMatching(q querier, userId int, m models.TodoQueries) ([]int, error) {
+	where, args := todoFilter(userId, m)
+	rows, err := q.Query("SELECT id FROM todos"+where+" ORDER BY id FOR UPDATE", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
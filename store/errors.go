@@ -0,0 +1,65 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Match them with errors.Is; the concrete error is an *Error
+// (or a *ConflictError for version conflicts) carrying the details.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// Error is a domain error of one of the kinds above. Message is safe to show
+// to clients; Fields lists per-field problems of validation errors.
+type Error struct {
+	Kind    error
+	Message string
+	Fields  []models.FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...interface{}) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unauthorized(format string, args ...interface{}) error {
+	return &Error{Kind: ErrUnauthorized, Message: fmt.Sprintf(format, args...)}
+}
+
+func Forbidden(format string, args ...interface{}) error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validation reports invalid input; fields may be empty when the problem is
+// not tied to a single field.
+func Validation(message string, fields ...models.FieldError) error {
+	return &Error{Kind: ErrValidation, Message: message, Fields: fields}
+}
+
+// todoNotFound translates sql.ErrNoRows from a todo lookup.
+func todoNotFound(id int, err error) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("todo %d not found", id), Err: err}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// HistoryFor returns the last recorded changes, at most last per todo, of
+// the user's todos with the given ids, oldest first, keyed by todo id.
+// Deleted todos keep their history.
+func (s *TodoStore) HistoryFor(ctx context.Context, userId int, todoIDs []int, last int) (_ map[int][]models.HistoryEntry, err error) {
+	ctx, op := s.begin(ctx, "HistoryFor")
+	defer op.end(&err)
+	ids := make([]int64, len(todoIDs))
+	for i, id := range todoIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := s.conn(ctx).Query(
+		`SELECT seq, todo_id, change_type, old_value, new_value, created_at FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY todo_id ORDER BY id DESC) AS n FROM todo_history
+			WHERE user_id=$1 AND todo_id = ANY($2)
+		) h WHERE n <= $3 ORDER BY id`,
+		userId, pq.Array(ids), last,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make(map[int][]models.HistoryEntry)
+	for rows.Next() {
+		var e models.HistoryEntry
+		var seq sql.NullInt64
+		var changeType, oldValue, newValue sql.NullString
+		if err := rows.Scan(&seq, &e.TodoId, &changeType, &oldValue, &newValue, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Seq = seq.Int64
+		e.Type = changeType.String
+		if e.Type != models.EventTodoCreated {
+			e.Old = historyValue(oldValue)
+		}
+		if e.Type != models.EventTodoDeleted {
+			e.New = historyValue(newValue)
+		}
+		history[e.TodoId] = append(history[e.TodoId], e)
+	}
+	return history, rows.Err()
+}
+
+// historyValue decodes a stored todo snapshot. Rows written before values
+// were stored as JSON cannot be decoded and yield nil.
+func historyValue(v sql.NullString) *models.Todo {
+	if !v.Valid {
+		return nil
+	}
+	var t models.Todo
+	if err := json.Unmarshal([]byte(v.String), &t); err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/safety"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// LockoutPolicy throttles password guessing per account. After a failed
+// login the account cannot be tried again for Delay, doubled with every
+// further failure in a row; Threshold failures in a row lock it for
+// Duration. A successful login starts over. With a zero Threshold failures
+// are only recorded.
+type LockoutPolicy struct {
+	Threshold int
+	Delay     time.Duration
+	Duration  time.Duration
+}
+
+// wait returns how long the account is locked after its failures-th failed
+// login in a row.
+func (p LockoutPolicy) wait(failures int) time.Duration {
+	if p.Threshold <= 0 {
+		return 0
+	}
+	if failures >= p.Threshold {
+		return p.Duration
+	}
+	wait := p.Delay
+	for i := 1; i < failures && wait < p.Duration; i++ {
+		wait *= 2
+	}
+	return min(wait, p.Duration)
+}
+
+// LockedError is returned for logins to an account that is locked after
+// failed logins. RetryAfter is how long the lock lasts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "account temporarily locked after failed logins"
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// dummyHash is compared against the password of logins to unknown users,
+// so that they take as long as logins to existing ones.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := safety.GenerateFromPassword([]byte(rand.Text()))
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// CheckUserCredentials returns the id of the user with the given name and
+// password. Unknown users and wrong passwords fail alike with ErrUnauthorized,
+// and locked accounts with a *LockedError. Failed logins to unknown names
+// are counted and locked like those of users, and every attempt compares a
+// password, so that neither the response nor its timing tells whether a
+// name is taken. The outcome is recorded as an auth event of the user.
+//
+// The user's row stays locked while the password is compared, so that
+// concurrent guesses are counted one after another.
+func (s *TodoStore) CheckUserCredentials(ctx context.Context, username, password string, client models.Client) (_ int, err error) {
+	ctx, op := s.begin(ctx, "CheckUserCredentials")
+	defer op.end(&err)
+
+	var (
+		userID int
+		// denied is returned after the transaction commits, so that
+		// failed logins are counted.
+		denied error
+	)
+	err = s.withTx(ctx, func(t *tx) error {
+		var (
+			hash        string
+			failures    int
+			lockedUntil *time.Time
+			disabled    bool
+			now         time.Time
+		)
+		err := t.QueryRow(
+			"SELECT id, password, failed_logins, locked_until, disabled_at IS NOT NULL, NOW() FROM users WHERE username=$1 FOR UPDATE",
+			username,
+		).Scan(&userID, &hash, &failures, &lockedUntil, &disabled, &now)
+		if err == sql.ErrNoRows {
+			denied, err = s.failUnknownUser(t, username, password)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if lockedUntil != nil && lockedUntil.After(now) {
+			safety.CompareHashAndPassword([]byte(hash), []byte(password))
+			denied = &LockedError{RetryAfter: lockedUntil.Sub(now)}
+			return nil
+		}
+
+		if safety.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			failures++
+			wait := s.Lockout.wait(failures)
+			_, err := t.Exec(
+				"UPDATE users SET failed_logins=$2, locked_until=NOW() + $3 * interval '1 microsecond' WHERE id=$1",
+				userID, failures, wait.Microseconds(),
+			)
+			if err != nil {
+				return err
+			}
+			if err := recordAuthEvent(t, userID, models.AuthEventLoginFailed, client); err != nil {
+				return err
+			}
+			if s.Lockout.Threshold > 0 && failures >= s.Lockout.Threshold {
+				if err := recordAuthEvent(t, userID, models.AuthEventAccountLocked, client); err != nil {
+					return err
+				}
+			}
+			denied = Unauthorized("invalid credentials")
+			return nil
+		}
+
+		if disabled {
+			denied = Unauthorized("user disabled")
+			return nil
+		}
+		if failures > 0 {
+			if _, err := t.Exec("UPDATE users SET failed_logins=0, locked_until=NULL WHERE id=$1", userID); err != nil {
+				return err
+			}
+		}
+		return recordAuthEvent(t, userID, models.AuthEventLoginSucceeded, client)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if denied != nil {
+		return 0, denied
+	}
+	return userID, nil
+}
+
+// loginFailuresTTL is how long the failures of an unknown name are kept
+// after the last one, unless the lockout lasts longer.
+const loginFailuresTTL = 24 * time.Hour
+
+// failUnknownUser denies a login to a name without a user, counting the
+// failure in login_failures the way CheckUserCredentials counts those of
+// users. Failures not repeated within loginFailuresTTL are forgotten.
+func (s *TodoStore) failUnknownUser(t *tx, username, password string) (denied error, err error) {
+	safety.CompareHashAndPassword(dummyHash(), []byte(password))
+	sum := sha256.Sum256([]byte(username))
+	key := hex.EncodeToString(sum[:])
+
+	var (
+		lockedUntil *time.Time
+		now         time.Time
+	)
+	err = t.QueryRow("SELECT locked_until, NOW() FROM login_failures WHERE username_hash=$1 FOR UPDATE", key).Scan(&lockedUntil, &now)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if lockedUntil != nil && lockedUntil.After(now) {
+		return &LockedError{RetryAfter: lockedUntil.Sub(now)}, nil
+	}
+
+	var failures int
+	err = t.QueryRow(
+		`INSERT INTO login_failures(username_hash, failed_logins) VALUES($1, 1)
+		ON CONFLICT (username_hash) DO UPDATE SET failed_logins = login_failures.failed_logins + 1, updated_at = NOW()
+		RETURNING failed_logins`,
+		key,
+	).Scan(&failures)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.Exec(
+		"UPDATE login_failures SET locked_until=NOW() + $2 * interval '1 microsecond' WHERE username_hash=$1",
+		key, s.Lockout.wait(failures).Microseconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.Exec(
+		"DELETE FROM login_failures WHERE updated_at < NOW() - $1 * interval '1 microsecond'",
+		max(loginFailuresTTL, s.Lockout.Duration).Microseconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return Unauthorized("invalid credentials"), nil
+}
+
+// UnlockUser clears the failed logins of a user, lifting any lockout.
+func (s *TodoStore) UnlockUser(ctx context.Context, id int) (err error) {
+	ctx, op := s.begin(ctx, "UnlockUser")
+	defer op.end(&err)
+	return s.withTx(ctx, func(t *tx) error {
+		res, err := t.Exec("UPDATE users SET failed_logins=0, locked_until=NULL WHERE id=$1", id)
+		if err != nil {
+			return err
+		}
+		if err := userAffected(id, res); err != nil {
+			return err
+		}
+		return recordAuthEvent(t, id, models.AuthEventAccountUnlocked, models.Client{})
+	})
+}
+
+// AuthEvents returns the latest auth events of a user, newest first.
+func (s *TodoStore) AuthEvents(ctx context.Context, userID int, limit int) (_ []models.AuthEvent, err error) {
+	ctx, op := s.begin(ctx, "AuthEvents")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query(
+		"SELECT event_type, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at FROM auth_events WHERE user_id=$1 ORDER BY id DESC LIMIT $2",
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuthEvent{}
+	for rows.Next() {
+		var e models.AuthEvent
+		if err := rows.Scan(&e.Type, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func recordAuthEvent(q querier, userID int, eventType string, client models.Client) error {
+	_, err := q.Exec(
+		"INSERT INTO auth_events(user_id, event_type, ip, user_agent) VALUES($1, $2, NULLIF($3, ''), NULLIF($4, ''))",
+		userID, eventType, client.IP, client.UserAgent,
+	)
+	return err
+}
@@ -2,13 +2,34 @@ package store
 
 import (
 	models "ToDoProject/models"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 
 	_ "github.com/lib/pq"
 )
 
-func (s *TodoStore) recordHistory(todoID, userID int, oldData, newData models.Todo) error {
+// recordChange writes the history row and the outbox event for a single
+// todo mutation. It must run in the same transaction as the mutation.
+func (s *TodoStore) recordChange(t *tx, eventType string, oldData, newData models.Todo) error {
+	todo := newData
+	if eventType == models.EventTodoDeleted {
+		todo = oldData
+	}
+
+	seq, err := s.recordHistory(t, eventType, todo.ID, todo.UserId, oldData, newData)
+	if err != nil {
+		return err
+	}
+	t.changes = append(t.changes, change{eventType: eventType, old: oldData, new: newData})
+	return s.recordEvent(t, eventType, seq, todo)
+}
+
+// recordHistory writes a history row stamped with the user's next change
+// sequence number and returns the number. Allocating the number locks the
+// user's sequence row until the transaction ends, so sequence numbers become
+// visible in order.
+func (s *TodoStore) recordHistory(q querier, eventType string, todoID, userID int, oldData, newData models.Todo) (int64, error) {
 	oldB, err := json.Marshal(oldData)
 	if err != nil {
 		oldB = []byte(fmt.Sprintf("%+v", oldData))
@@ -19,24 +40,57 @@ func (s *TodoStore) recordHistory(todoID, userID int, oldData, newData models.To
 		newB = []byte(fmt.Sprintf("%+v", newData))
 	}
 
-	_, err = s.DB.Exec(
-		"INSERT INTO todo_history(todo_id, user_id, old_value, new_value) VALUES($1, $2, $3, $4)",
-		todoID, userID, string(oldB), string(newB),
+	var seq int64
+	err = q.QueryRow(
+		`INSERT INTO user_change_seq(user_id, last_seq) VALUES($1, 1)
+		ON CONFLICT (user_id) DO UPDATE SET last_seq = user_change_seq.last_seq + 1
+		RETURNING last_seq`,
+		userID,
+	).Scan(&seq)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO todo_history(todo_id, user_id, seq, change_type, old_value, new_value) VALUES($1, $2, $3, $4, $5, $6)",
+		todoID, userID, seq, eventType, string(oldB), string(newB),
 	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return seq, nil
 }
 
-func (s *TodoStore) getTodo(id, userId int) (models.Todo, error) {
+func (s *TodoStore) getTodo(q querier, id, userId int) (models.Todo, error) {
 	var t models.Todo
-	err := s.DB.QueryRow(
-		"SELECT id, user_id, title, description, created_at, done FROM todos WHERE user_id=$1 AND id=$2",
+	err := scanTodo(q.QueryRow(
+		"SELECT "+todoColumns+" FROM todos WHERE user_id=$1 AND id=$2",
 		userId, id,
-	).Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done)
+	), &t)
 
+	if err == sql.ErrNoRows {
+		return models.Todo{}, todoNotFound(id, err)
+	}
+	if err != nil {
+		return models.Todo{}, err
+	}
+
+	return t, nil
+}
+
+// lockTodo loads a todo and locks its row until the surrounding transaction
+// ends, so the old value recorded in history is the one being replaced.
+func (s *TodoStore) lockTodo(q querier, id, userId int) (models.Todo, error) {
+	var t models.Todo
+	err := scanTodo(q.QueryRow(
+		"SELECT "+todoColumns+" FROM todos WHERE user_id=$1 AND id=$2 FOR UPDATE",
+		userId, id,
+	), &t)
+
+	if err == sql.ErrNoRows {
+		return models.Todo{}, todoNotFound(id, err)
+	}
 	if err != nil {
 		return models.Todo{}, err
 	}
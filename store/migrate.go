@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the advisory lock key that serialises concurrent
+// migration runs.
+const migrationLock = 0x746f646f
+
+// Migration is one schema change, named after its file without the .sql
+// suffix. AppliedAt is zero for pending migrations.
+type Migration struct {
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migrations lists all known migrations in order, with the time each was
+// applied.
+func (s *TodoStore) Migrations(ctx context.Context) (_ []Migration, err error) {
+	ctx, op := s.begin(ctx, "Migrations")
+	defer op.end(&err)
+	names, err := migrationNames()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations(s.conn(ctx))
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, len(names))
+	for i, name := range names {
+		migrations[i] = Migration{Name: name, AppliedAt: applied[name]}
+	}
+	return migrations, nil
+}
+
+// Migrate applies the pending migrations in one transaction and returns
+// their names. Either all of them are applied or none.
+func (s *TodoStore) Migrate(ctx context.Context) (_ []string, err error) {
+	ctx, op := s.begin(ctx, "Migrate")
+	defer op.end(&err)
+	names, err := migrationNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	err = s.withTx(ctx, func(t *tx) error {
+		if _, err := t.Exec("SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+			return err
+		}
+		_, err := t.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			name TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`)
+		if err != nil {
+			return err
+		}
+		applied, err := s.appliedMigrations(t)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			if _, ok := applied[name]; ok {
+				continue
+			}
+			script, err := migrationFiles.ReadFile(path.Join("migrations", name+".sql"))
+			if err != nil {
+				return err
+			}
+			if _, err := t.Exec(string(script)); err != nil {
+				return fmt.Errorf("migration %s: %w", name, err)
+			}
+			if _, err := t.Exec("INSERT INTO schema_migrations(name) VALUES($1)", name); err != nil {
+				return err
+			}
+			done = append(done, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// appliedMigrations reads schema_migrations. A database that has never been
+// migrated has no such table and nothing applied.
+func (s *TodoStore) appliedMigrations(q querier) (map[string]time.Time, error) {
+	applied := make(map[string]time.Time)
+	var exists bool
+	if err := q.QueryRow("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := q.Query("SELECT name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		applied[name] = at
+	}
+	return applied, rows.Err()
+}
+
+func migrationNames() ([]string, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = strings.TrimSuffix(path.Base(f), ".sql")
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,54 @@
+-- The schema as it was set up by hand before migrations existed. Every
+-- statement is idempotent so existing databases can adopt migrations;
+-- 0008_adopt_baseline adds what those databases lack.
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id),
+    title TEXT NOT NULL,
+    description TEXT,
+    done BOOLEAN DEFAULT FALSE,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS todo_history (
+    id SERIAL PRIMARY KEY,
+    todo_id INT NOT NULL,
+    user_id INT REFERENCES users(id),
+    seq BIGINT,
+    change_type TEXT,
+    old_value TEXT,
+    new_value TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS todo_history_user_seq ON todo_history(user_id, seq);
+
+CREATE TABLE IF NOT EXISTS user_change_seq (
+    user_id INT PRIMARY KEY REFERENCES users(id),
+    last_seq BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id),
+    todo_id INT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+    event_id BIGINT REFERENCES outbox(id) ON DELETE CASCADE,
+    consumer TEXT NOT NULL,
+    delivered_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (event_id, consumer)
+);
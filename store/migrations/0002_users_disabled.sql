@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS todo_history_created_at ON todo_history(created_at);
@@ -0,0 +1,6 @@
+-- Token buckets of the PostgreSQL rate limiter backend. tat is the time at
+-- which the bucket is full again; rows past it are swept.
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    tat TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,12 @@
+-- Personal access tokens. Only the SHA-256 hash of a token is stored.
+CREATE TABLE IF NOT EXISTS personal_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
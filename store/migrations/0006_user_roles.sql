@@ -0,0 +1,2 @@
+-- Roles such as admin, granted with todoadmin.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{}';
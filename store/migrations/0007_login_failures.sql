@@ -0,0 +1,11 @@
+-- Failed logins to usernames that do not exist, counted like those of
+-- users so that lockouts do not tell which names are taken. Names are
+-- stored as SHA-256 digests rather than in the clear.
+CREATE TABLE IF NOT EXISTS login_failures (
+    username_hash TEXT PRIMARY KEY,
+    failed_logins INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_updated_at ON login_failures(updated_at);
@@ -0,0 +1,33 @@
+-- Brings a database built by hand from the original schema up to what
+-- 0001_init expects, since CREATE TABLE IF NOT EXISTS leaves existing
+-- tables alone. Every statement is a no-op on databases created by 0001.
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+-- History rows outlive their todos, so deleting a todo must not be blocked
+-- by its history.
+ALTER TABLE todo_history DROP CONSTRAINT IF EXISTS todo_history_todo_id_fkey;
+
+ALTER TABLE todo_history ADD COLUMN IF NOT EXISTS seq BIGINT;
+ALTER TABLE todo_history ADD COLUMN IF NOT EXISTS change_type TEXT;
+
+-- Old rows recorded the zero todo, with id 0, as the value before a create
+-- and after a delete.
+UPDATE todo_history SET change_type = CASE
+        WHEN new_value LIKE '{"id":0,%' THEN 'deleted'
+        WHEN old_value LIKE '{"id":0,%' THEN 'created'
+        ELSE 'updated'
+    END
+WHERE change_type IS NULL;
+
+-- Number old rows per user in the order they were written.
+UPDATE todo_history h SET seq = n.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id) AS seq
+    FROM todo_history WHERE seq IS NULL
+) n
+WHERE h.id = n.id;
+
+INSERT INTO user_change_seq(user_id, last_seq)
+SELECT user_id, MAX(seq) FROM todo_history WHERE user_id IS NOT NULL GROUP BY user_id
+ON CONFLICT (user_id) DO UPDATE SET last_seq = GREATEST(user_change_seq.last_seq, EXCLUDED.last_seq);
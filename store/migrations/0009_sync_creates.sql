@@ -0,0 +1,10 @@
+-- Creates pushed through /sync, keyed by the client's id for them, so that a
+-- push that is sent again does not create its todos twice. Rows outlive the
+-- todos they created; todo_id is NULL only inside the creating transaction.
+CREATE TABLE IF NOT EXISTS sync_creates (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id TEXT NOT NULL,
+    todo_id INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, client_id)
+);
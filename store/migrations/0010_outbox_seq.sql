@@ -0,0 +1,10 @@
+-- Outbox events carry the change sequence number of their history row.
+-- Event ids are taken when a row is inserted, so a user's events can commit
+-- out of id order; sequence numbers are taken under a lock held until the
+-- transaction ends, so they commit in order and streams resume by them.
+-- Existing events are numbered below every new one, in id order.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS seq BIGINT;
+UPDATE outbox SET seq = id - (SELECT MAX(id) FROM outbox) - 1 WHERE seq IS NULL;
+ALTER TABLE outbox ALTER COLUMN seq SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_user_seq ON outbox(user_id, seq);
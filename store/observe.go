@@ -0,0 +1,59 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Observer is told how long each store operation took and about every todo
+// change once it has committed, e.g. to export metrics. Its methods are
+// called from many goroutines and must not block.
+type Observer interface {
+	ObserveOperation(method string, duration time.Duration, err error)
+	ObserveChange(eventType string, old, new models.Todo)
+}
+
+// change is a todo mutation recorded in a transaction, reported to the
+// observer after commit.
+type change struct {
+	eventType string
+	old, new  models.Todo
+}
+
+// operation is a call of an exported store method. Each method starts one
+// with begin and defers end with its named error result.
+type operation struct {
+	s      *TodoStore
+	method string
+	start  time.Time
+	span   trace.Span
+}
+
+// begin starts the span of the named method. The returned context carries
+// the span, so the statements the method runs become its children.
+func (s *TodoStore) begin(ctx context.Context, method string) (context.Context, *operation) {
+	ctx, span := tracer.Start(ctx, "store."+method, trace.WithAttributes(semconv.DBSystemNamePostgreSQL))
+	return ctx, &operation{s: s, method: method, start: time.Now(), span: span}
+}
+
+// end ends the span and reports the duration and outcome to the observer.
+func (o *operation) end(err *error) {
+	endSpan(o.span, *err)
+	if o.s.Observer != nil {
+		o.s.Observer.ObserveOperation(o.method, time.Since(o.start), *err)
+	}
+}
+
+// observeChanges hands committed changes to the observer, if any.
+func (s *TodoStore) observeChanges(changes []change) {
+	if s.Observer == nil {
+		return
+	}
+	for _, c := range changes {
+		s.Observer.ObserveChange(c.eventType, c.old, c.new)
+	}
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const eventColumns = "id, user_id, todo_id, seq, event_type, payload, created_at"
+
+// recordEvent writes an outbox event stamped with the change sequence
+// number of its history row. The outbox relay publishes it to the consumers
+// once the transaction has committed.
+func (s *TodoStore) recordEvent(t *tx, eventType string, seq int64, todo models.Todo) error {
+	payload, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+
+	e := models.TodoEvent{Seq: seq, UserId: todo.UserId, TodoId: todo.ID, Type: eventType, Payload: todo}
+	err = t.QueryRow(
+		"INSERT INTO outbox(user_id, todo_id, seq, event_type, payload) VALUES($1, $2, $3, $4, $5) RETURNING id, created_at",
+		todo.UserId, todo.ID, seq, eventType, string(payload),
+	).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	t.events = append(t.events, e)
+	return nil
+}
+
+// publish hands committed events to the in-process publisher, if any, which
+// wakes the outbox relay.
+func (s *TodoStore) publish(events []models.TodoEvent) {
+	if s.Publisher == nil {
+		return
+	}
+	for _, e := range events {
+		s.Publisher.Publish(e)
+	}
+}
+
+// GetEvent loads a single outbox event by id.
+func (s *TodoStore) GetEvent(ctx context.Context, id int64) (_ models.TodoEvent, err error) {
+	ctx, op := s.begin(ctx, "GetEvent")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query(
+		"SELECT "+eventColumns+" FROM outbox WHERE id=$1",
+		id,
+	)
+	if err != nil {
+		return models.TodoEvent{}, err
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return models.TodoEvent{}, err
+	}
+	if len(events) == 0 {
+		return models.TodoEvent{}, NotFound("event %d not found", id)
+	}
+	return events[0], nil
+}
+
+// EventSeq returns the change sequence number to resume the user's events
+// after the event with the given id. Event ids are not in commit order, so
+// streams resume by sequence number. When the event has been pruned it
+// returns the greatest sequence number of the user's older events, or one
+// less than the smallest retained when there are none.
+func (s *TodoStore) EventSeq(ctx context.Context, userId int, id int64) (_ int64, err error) {
+	ctx, op := s.begin(ctx, "EventSeq")
+	defer op.end(&err)
+	var seq sql.NullInt64
+	err = s.conn(ctx).QueryRow(
+		`SELECT COALESCE(
+			(SELECT seq FROM outbox WHERE user_id=$1 AND id=$2),
+			(SELECT MAX(seq) FROM outbox WHERE user_id=$1 AND id<$2),
+			(SELECT MIN(seq) - 1 FROM outbox WHERE user_id=$1))`,
+		userId, id,
+	).Scan(&seq)
+	if err != nil {
+		return 0, err
+	}
+	return seq.Int64, nil
+}
+
+// EventsSince returns the user's outbox events with a change sequence
+// number greater than afterSeq, in commit order.
+func (s *TodoStore) EventsSince(ctx context.Context, userId int, afterSeq int64, limit int) (_ []models.TodoEvent, err error) {
+	ctx, op := s.begin(ctx, "EventsSince")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query(
+		"SELECT "+eventColumns+" FROM outbox WHERE user_id=$1 AND seq>$2 ORDER BY seq LIMIT $3",
+		userId, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// DeliverOutbox hands up to limit undelivered outbox events to handle, in id
+// order, on behalf of the named consumer. Each successfully handled event is
+// marked as delivered for that consumer in the same transaction, and a
+// per-consumer advisory lock keeps replicas from delivering concurrently.
+// Delivery stops at the first handler error; the failed event is retried on
+// the next call. It returns the number of events delivered.
+func (s *TodoStore) DeliverOutbox(ctx context.Context, consumer string, limit int, handle func(models.TodoEvent) error) (_ int, err error) {
+	ctx, op := s.begin(ctx, "DeliverOutbox")
+	defer op.end(&err)
+	delivered := 0
+	err = s.withTx(ctx, func(tx *tx) error {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "outbox:"+consumer); err != nil {
+			return err
+		}
+
+		events, err := s.pendingEvents(tx, consumer, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := handle(event); err != nil {
+				break
+			}
+			_, err := tx.Exec(
+				"INSERT INTO outbox_deliveries(event_id, consumer) VALUES($1, $2)",
+				event.ID, consumer,
+			)
+			if err != nil {
+				return err
+			}
+			delivered++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delivered, nil
+}
+
+// NotifyEvent announces an outbox event on NotifyChannel to the event
+// listeners of every replica.
+func (s *TodoStore) NotifyEvent(ctx context.Context, id int64) (err error) {
+	ctx, op := s.begin(ctx, "NotifyEvent")
+	defer op.end(&err)
+	_, err = s.conn(ctx).Exec("SELECT pg_notify($1, $2)", NotifyChannel, strconv.FormatInt(id, 10))
+	return err
+}
+
+// PruneOutbox deletes outbox events created before the cutoff that every
+// one of consumers has been delivered, along with their deliveries. Events
+// a consumer still waits for are kept however old they are. It returns the
+// number of events deleted.
+func (s *TodoStore) PruneOutbox(ctx context.Context, consumers []string, before time.Time) (_ int64, err error) {
+	ctx, op := s.begin(ctx, "PruneOutbox")
+	defer op.end(&err)
+	res, err := s.conn(ctx).Exec(
+		`DELETE FROM outbox o WHERE o.created_at < $1
+		AND (SELECT COUNT(*) FROM outbox_deliveries d WHERE d.event_id = o.id AND d.consumer = ANY($2)) = cardinality($2::text[])`,
+		before, pq.Array(consumers),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *TodoStore) pendingEvents(q querier, consumer string, limit int) ([]models.TodoEvent, error) {
+	rows, err := q.Query(
+		`SELECT o.id, o.user_id, o.todo_id, o.seq, o.event_type, o.payload, o.created_at FROM outbox o
+		WHERE NOT EXISTS (SELECT 1 FROM outbox_deliveries d WHERE d.event_id = o.id AND d.consumer = $1)
+		ORDER BY o.id LIMIT $2`,
+		consumer, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+func scanEvents(rows *sql.Rows) ([]models.TodoEvent, error) {
+	var events []models.TodoEvent
+	for rows.Next() {
+		var e models.TodoEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.UserId, &e.TodoId, &e.Seq, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &e.Payload); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	_ "github.com/lib/pq"
+)
+
+// ConflictError is returned when a versioned write was based on an outdated
+// version of the todo. Current is the todo as it is stored now.
+type ConflictError struct {
+	Current models.Todo
+}
+
+func (e *ConflictError) Error() string {
+	return "todo was modified since base version"
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ChangesSince reads up to limit history rows of the user with a change
+// sequence greater than since and returns the latest change per todo, in
+// sequence order, together with the last sequence number read. more reports
+// whether further rows may remain.
+func (s *TodoStore) ChangesSince(ctx context.Context, userId int, since int64, limit int) (changes []models.TodoChange, last int64, more bool, err error) {
+	ctx, op := s.begin(ctx, "ChangesSince")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query(
+		"SELECT seq, todo_id, change_type, new_value FROM todo_history WHERE user_id=$1 AND seq>$2 ORDER BY seq LIMIT $3",
+		userId, since, limit,
+	)
+	if err != nil {
+		return nil, since, false, err
+	}
+	defer rows.Close()
+
+	last = since
+	count := 0
+	latest := make(map[int]int)
+	for rows.Next() {
+		var c models.TodoChange
+		var changeType, newValue string
+		if err := rows.Scan(&c.Seq, &c.TodoId, &changeType, &newValue); err != nil {
+			return nil, since, false, err
+		}
+		count++
+		last = c.Seq
+
+		if changeType == models.EventTodoDeleted {
+			c.Deleted = true
+		} else {
+			var t models.Todo
+			if err := json.Unmarshal([]byte(newValue), &t); err != nil {
+				return nil, since, false, err
+			}
+			c.Todo = &t
+		}
+
+		if i, ok := latest[c.TodoId]; ok {
+			changes[i] = models.TodoChange{}
+		}
+		latest[c.TodoId] = len(changes)
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, since, false, err
+	}
+
+	compacted := changes[:0]
+	for _, c := range changes {
+		if c.Seq != 0 {
+			compacted = append(compacted, c)
+		}
+	}
+	return compacted, last, count == limit, nil
+}
+
+// SyncCreate creates a todo pushed by a sync client in one transaction.
+// clientID is the client's id for the change: a create the user already
+// pushed under the same clientID is not repeated, and the todo it created
+// is returned instead, or a not-found error when that todo has since been
+// deleted. An empty clientID is not deduplicated.
+func (s *TodoStore) SyncCreate(ctx context.Context, userId int, clientID string, title string, description string, done bool) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "SyncCreate")
+	defer op.end(&err)
+	var t models.Todo
+	err = s.withTx(ctx, func(tx *tx) error {
+		if clientID != "" {
+			// A concurrent push of the same create waits here until the
+			// first one commits, and then sees its row.
+			res, err := tx.Exec(
+				"INSERT INTO sync_creates(user_id, client_id) VALUES($1, $2) ON CONFLICT DO NOTHING",
+				userId, clientID,
+			)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				var todoID int
+				err := tx.QueryRow("SELECT todo_id FROM sync_creates WHERE user_id=$1 AND client_id=$2", userId, clientID).Scan(&todoID)
+				if err != nil {
+					return err
+				}
+				t, err = s.getTodo(tx, todoID, userId)
+				return err
+			}
+		}
+
+		var err error
+		t, err = s.create(tx, userId, title, description, done)
+		if err != nil || clientID == "" {
+			return err
+		}
+		_, err = tx.Exec("UPDATE sync_creates SET todo_id=$3 WHERE user_id=$1 AND client_id=$2", userId, clientID, t.ID)
+		return err
+	})
+	return t, err
+}
+
+// VersionedUpdate applies the non-nil fields of model when baseVersion is
+// still the todo's current version, and returns a *ConflictError otherwise.
+func (s *TodoStore) VersionedUpdate(ctx context.Context, userId int, id int, baseVersion int, model models.TodoUpdateHandlerRequest) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "VersionedUpdate")
+	defer op.end(&err)
+	var t models.Todo
+	err = s.withTx(ctx, func(tx *tx) error {
+		var err error
+		t, err = s.update(tx, userId, id, &baseVersion, applyFields(model))
+		return err
+	})
+	return t, err
+}
+
+// VersionedDelete deletes the todo when baseVersion is still its current
+// version, and returns a *ConflictError otherwise.
+func (s *TodoStore) VersionedDelete(ctx context.Context, userId int, id int, baseVersion int) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "VersionedDelete")
+	defer op.end(&err)
+	var t models.Todo
+	err = s.withTx(ctx, func(tx *tx) error {
+		var err error
+		t, err = s.remove(tx, userId, id, &baseVersion)
+		return err
+	})
+	return t, err
+}
+
+// Snapshot returns all todos of the user together with the change sequence
+// they reflect, read from one consistent snapshot of the database.
+func (s *TodoStore) Snapshot(ctx context.Context, userId int) (_ []models.Todo, _ int64, err error) {
+	ctx, op := s.begin(ctx, "Snapshot")
+	defer op.end(&err)
+	sqlTx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer sqlTx.Rollback()
+	q := conn{ctx: ctx, c: sqlTx}
+
+	var seq int64
+	err = q.QueryRow("SELECT last_seq FROM user_change_seq WHERE user_id=$1", userId).Scan(&seq)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, err
+	}
+
+	rows, err := q.Query("SELECT "+todoColumns+" FROM todos WHERE user_id=$1 ORDER BY id", userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var todos []models.Todo
+	for rows.Next() {
+		var t models.Todo
+		if err := scanTodo(rows, &t); err != nil {
+			return nil, 0, err
+		}
+		todos = append(todos, t)
+	}
+	return todos, seq, rows.Err()
+}
@@ -2,16 +2,30 @@ package store
 
 import (
 	models "ToDoProject/models"
-	"ToDoProject/utils"
+	"context"
 	"database/sql"
+	"slices"
 	"strconv"
 	"strings"
 
 	_ "github.com/lib/pq"
 )
 
+// NotifyChannel is the PostgreSQL channel on which the outbox relay
+// announces committed event ids to the event listeners of all replicas.
+const NotifyChannel = "todo_events"
+
+// EventPublisher receives todo events after the transaction that produced
+// them has committed.
+type EventPublisher interface {
+	Publish(event models.TodoEvent)
+}
+
 type TodoStore struct {
-	DB *sql.DB
+	DB        *sql.DB
+	Publisher EventPublisher
+	Observer  Observer
+	Lockout   LockoutPolicy
 }
 
 func NewTodoStore(connStr string) (*TodoStore, error) {
@@ -25,20 +39,41 @@ func NewTodoStore(connStr string) (*TodoStore, error) {
 	return &TodoStore{DB: db}, nil
 }
 
-func (s *TodoStore) Create(userId int, title string, description string) (models.Todo, error) {
+// todoColumns is the column list matching scanTodo.
+const todoColumns = "id, user_id, title, description, created_at, done, version"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTodo(row rowScanner, t *models.Todo) error {
+	return row.Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done, &t.Version)
+}
+
+// Create stores a new todo, already done when done is set, as one change
+// with a single created event.
+func (s *TodoStore) Create(ctx context.Context, userId int, title string, description string, done bool) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "Create")
+	defer op.end(&err)
 	var t models.Todo
-	err := s.DB.QueryRow(
-		"INSERT INTO todos(user_id, title, description, done) VALUES($1, $2, $3, $4) RETURNING id, user_id, title, description, created_at, done",
-		userId, title, description, false,
-	).Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done)
-	if err == nil {
-		s.recordHistory(t.ID, userId, models.Todo{}, t)
-	}
+	err = s.withTx(ctx, func(tx *tx) error {
+		var err error
+		t, err = s.create(tx, userId, title, description, done)
+		return err
+	})
 	return t, err
 }
 
-func (s *TodoStore) List(userId int) ([]models.Todo, error) {
-	rows, err := s.DB.Query("SELECT id, user_id, title, description, created_at, done FROM todos WHERE user_id=$1 ORDER BY id", userId)
+func (s *TodoStore) Get(ctx context.Context, userId int, id int) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "Get")
+	defer op.end(&err)
+	return s.getTodo(s.conn(ctx), id, userId)
+}
+
+func (s *TodoStore) List(ctx context.Context, userId int) (_ []models.Todo, err error) {
+	ctx, op := s.begin(ctx, "List")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query("SELECT "+todoColumns+" FROM todos WHERE user_id=$1 ORDER BY id", userId)
 	if err != nil {
 		return nil, err
 	}
@@ -47,55 +82,73 @@ func (s *TodoStore) List(userId int) ([]models.Todo, error) {
 	var todos []models.Todo
 	for rows.Next() {
 		var t models.Todo
-		rows.Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done)
+		scanTodo(rows, &t)
 		todos = append(todos, t)
 	}
 	return todos, nil
 }
 
-func (s *TodoStore) FilteredList(userId int, m models.TodoQueries) ([]models.Todo, error) {
-	query := "SELECT id, user_id, title, description, created_at, done FROM todos"
-	var args []interface{}
-	var conditions []string
-
-	if m.Done != nil {
-		args = append(args, *m.Done)
-		conditions = append(conditions, "done = $"+strconv.Itoa(len(args)))
-	}
-
-	if m.Title != nil && *m.Title != "" {
-		args = append(args, "%"+*m.Title+"%")
-		conditions = append(conditions, "description ILIKE $"+strconv.Itoa(len(args)))
+// FilteredList returns the user's todos matching the filter fields of m,
+// ordered and paged by its sort, order, limit and offset. Sort columns and
+// directions come from a fixed list and limit and offset are bound as
+// parameters, so no part of m is pasted into the statement; values outside
+// those fail with a validation error.
+func (s *TodoStore) FilteredList(ctx context.Context, userId int, m models.TodoQueries) (_ []models.Todo, err error) {
+	ctx, op := s.begin(ctx, "FilteredList")
+	defer op.end(&err)
+	where, args := todoFilter(userId, m)
+	orderBy, fields := todoOrder(m.Sort, m.Order)
+	limit, limitErr := pageParam("limit", m.Limit)
+	offset, offsetErr := pageParam("offset", m.Offset)
+	for _, f := range []*models.FieldError{limitErr, offsetErr} {
+		if f != nil {
+			fields = append(fields, *f)
+		}
 	}
-
-	if m.Description != nil && *m.Description != "" {
-		args = append(args, "%"+*m.Description+"%")
-		conditions = append(conditions, "title ILIKE $"+strconv.Itoa(len(args)))
+	if len(fields) > 0 {
+		return nil, Validation("invalid query", fields...)
 	}
 
-	if m.Timestamp != nil && *m.Timestamp != "" {
-		args = append(args, *m.Timestamp)
-		conditions = append(conditions, "created_at = $"+strconv.Itoa(len(args)))
+	query := "SELECT " + todoColumns + " FROM todos" + where + orderBy
+	if limit != nil {
+		args = append(args, *limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
 	}
-
-	args = append(args, userId)
-	conditions = append(conditions, "user_id = $"+strconv.Itoa(len(args)))
-
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	if offset != nil {
+		args = append(args, *offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
 	}
 
-	query += utils.DetermineOrder(m.Order, m.Sort)
-
-	if m.Limit != nil && *m.Limit != "" {
-		query += " LIMIT " + *m.Limit
+	rows, err := s.conn(ctx).Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if m.Offset != nil && *m.Offset != "" {
-		query += " OFFSET " + *m.Offset
+	var todos []models.Todo
+	for rows.Next() {
+		var t models.Todo
+		if err := scanTodo(rows, &t); err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
 	}
+	return todos, nil
+}
 
-	rows, err := s.DB.Query(query, args...)
+// TodoPage returns up to limit of the user's todos matching the filter
+// fields of m with an id greater than afterID, in id order. It backs
+// cursor-based pagination, which stays stable while todos are added.
+func (s *TodoStore) TodoPage(ctx context.Context, userId int, m models.TodoQueries, afterID int, limit int) (_ []models.Todo, err error) {
+	ctx, op := s.begin(ctx, "TodoPage")
+	defer op.end(&err)
+	where, args := todoFilter(userId, m)
+	args = append(args, afterID, limit)
+	rows, err := s.conn(ctx).Query(
+		"SELECT "+todoColumns+" FROM todos"+where+
+			" AND id > $"+strconv.Itoa(len(args)-1)+" ORDER BY id LIMIT $"+strconv.Itoa(len(args)),
+		args...,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -104,65 +157,251 @@ func (s *TodoStore) FilteredList(userId int, m models.TodoQueries) ([]models.Tod
 	var todos []models.Todo
 	for rows.Next() {
 		var t models.Todo
-		if err := rows.Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done); err != nil {
+		if err := scanTodo(rows, &t); err != nil {
 			return nil, err
 		}
 		todos = append(todos, t)
 	}
-	return todos, nil
+	return todos, rows.Err()
 }
 
-func (s *TodoStore) SoftUpdate(userId int, id int, model models.TodoUpdateHandlerRequest) (models.Todo, error) {
-	oldT, _ := s.getTodo(id, userId)
-	var t models.Todo
-	err := s.DB.QueryRow("SELECT title, description, done FROM todos WHERE id=$1 AND user_id=$2", id, userId).
-		Scan(&t.Title, &t.Description, &t.Done)
+// CountTodos returns how many of the user's todos match each of filters,
+// counted in one query.
+func (s *TodoStore) CountTodos(ctx context.Context, userId int, filters []models.TodoQueries) (_ []int, err error) {
+	ctx, op := s.begin(ctx, "CountTodos")
+	defer op.end(&err)
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{userId}
+	columns := make([]string, len(filters))
+	for i, m := range filters {
+		columns[i] = "COUNT(*)"
+		if conditions := todoConditions(m, &args); len(conditions) > 0 {
+			columns[i] += " FILTER (WHERE " + strings.Join(conditions, " AND ") + ")"
+		}
+	}
+
+	counts := make([]int, len(filters))
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	err = s.conn(ctx).QueryRow("SELECT "+strings.Join(columns, ", ")+" FROM todos WHERE user_id = $1", args...).Scan(dest...)
 	if err != nil {
-		return t, err
+		return nil, err
 	}
+	return counts, nil
+}
 
-	if model.Title != nil {
-		t.Title = *model.Title
+// sortColumns are the columns GET /todos may be sorted by.
+var sortColumns = []string{"id", "title", "description", "done", "created_at", "version"}
+
+// todoOrder returns the ORDER BY clause for the sort column and order
+// direction. Without either, todos are in id order; with only a direction,
+// they are sorted by created_at.
+func todoOrder(sort, order *string) (string, []models.FieldError) {
+	column, direction := "id", "ASC"
+	if order != nil && *order != "" {
+		column = "created_at"
 	}
-	if model.Description != nil {
-		t.Description = *model.Description
+	var fields []models.FieldError
+	if sort != nil && *sort != "" {
+		if slices.Contains(sortColumns, *sort) {
+			column = *sort
+		} else {
+			fields = append(fields, models.FieldError{Field: "sort", Message: "must be one of " + strings.Join(sortColumns, ", ")})
+		}
 	}
-	if model.Done != nil {
-		t.Done = *model.Done
+	if order != nil && *order != "" {
+		switch strings.ToLower(*order) {
+		case "asc":
+		case "desc":
+			direction = "DESC"
+		default:
+			fields = append(fields, models.FieldError{Field: "order", Message: "must be asc or desc"})
+		}
 	}
+	return " ORDER BY " + column + " " + direction, fields
+}
 
-	err = s.DB.QueryRow(
-		"UPDATE todos SET title=$1, description=$2, done=$3 WHERE id=$4 AND user_id=$5 RETURNING id, user_id, title, description, created_at, done",
-		t.Title, t.Description, t.Done, id, userId,
-	).Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done)
-	if err == nil {
-		s.recordHistory(t.ID, userId, oldT, t)
+// pageParam parses a limit or offset. Empty values give nil; anything but
+// a non-negative integer is reported as a field error.
+func pageParam(name string, v *string) (*int, *models.FieldError) {
+	if v == nil || *v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(*v)
+	if err != nil || n < 0 {
+		return nil, &models.FieldError{Field: name, Message: "must be a non-negative integer"}
 	}
+	return &n, nil
+}
+
+// todoFilter builds the WHERE clause and its arguments for the filter
+// fields of m, always restricted to the user's own todos. Ordering and
+// pagination are left to the caller.
+func todoFilter(userId int, m models.TodoQueries) (string, []interface{}) {
+	var args []interface{}
+	conditions := todoConditions(m, &args)
+	args = append(args, userId)
+	conditions = append(conditions, "user_id = $"+strconv.Itoa(len(args)))
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// todoConditions returns the conditions for the filter fields of m,
+// appending their arguments to args so that placeholders continue its
+// numbering.
+func todoConditions(m models.TodoQueries, args *[]interface{}) []string {
+	var conditions []string
+	arg := func(v interface{}) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+
+	if m.Done != nil {
+		conditions = append(conditions, "done = "+arg(*m.Done))
+	}
+
+	if m.Title != nil && *m.Title != "" {
+		conditions = append(conditions, "title ILIKE "+arg("%"+*m.Title+"%"))
+	}
+
+	if m.Description != nil && *m.Description != "" {
+		conditions = append(conditions, "description ILIKE "+arg("%"+*m.Description+"%"))
+	}
+
+	if m.Timestamp != nil && *m.Timestamp != "" {
+		conditions = append(conditions, "created_at = "+arg(*m.Timestamp))
+	}
+
+	if m.CreatedBefore != nil && *m.CreatedBefore != "" {
+		conditions = append(conditions, "created_at < "+arg(*m.CreatedBefore))
+	}
+
+	if m.CreatedAfter != nil && *m.CreatedAfter != "" {
+		conditions = append(conditions, "created_at > "+arg(*m.CreatedAfter))
+	}
+
+	return conditions
+}
+
+func (s *TodoStore) SoftUpdate(ctx context.Context, userId int, id int, model models.TodoUpdateHandlerRequest) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "SoftUpdate")
+	defer op.end(&err)
+	var t models.Todo
+	err = s.withTx(ctx, func(tx *tx) error {
+		var err error
+		t, err = s.update(tx, userId, id, nil, applyFields(model))
+		return err
+	})
 	return t, err
 }
 
-func (s *TodoStore) HardUpdate(userId int, id int, model models.TodoUpdateHandlerRequest) (models.Todo, error) {
-	oldT, _ := s.getTodo(id, userId)
+func (s *TodoStore) HardUpdate(ctx context.Context, userId int, id int, model models.TodoUpdateHandlerRequest) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "HardUpdate")
+	defer op.end(&err)
 	var t models.Todo
-	err := s.DB.QueryRow(
-		"UPDATE todos SET title=$1, description=$2, done=$3 WHERE id=$4 AND user_id=$5 RETURNING id, user_id, title, description, created_at, done",
-		model.Title, model.Description, model.Done, id, userId,
-	).Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done)
-	if err == nil {
-		s.recordHistory(t.ID, userId, oldT, t)
-	}
+	err = s.withTx(ctx, func(tx *tx) error {
+		var err error
+		t, err = s.update(tx, userId, id, nil, replaceFields(model))
+		return err
+	})
 	return t, err
 }
 
-func (s *TodoStore) Delete(userId int, id int) (models.Todo, error) {
-	oldT, _ := s.getTodo(id, userId)
+func (s *TodoStore) Delete(ctx context.Context, userId int, id int) (_ models.Todo, err error) {
+	ctx, op := s.begin(ctx, "Delete")
+	defer op.end(&err)
 	var t models.Todo
-	err := s.DB.QueryRow(
-		"DELETE FROM todos WHERE id=$1 AND user_id=$2 RETURNING id, user_id, title, description, created_at, done",
+	err = s.withTx(ctx, func(tx *tx) error {
+		var err error
+		t, err = s.remove(tx, userId, id, nil)
+		return err
+	})
+	return t, err
+}
+
+// applyFields returns a change that sets the non-nil fields of model.
+func applyFields(model models.TodoUpdateHandlerRequest) func(*models.Todo) {
+	return func(t *models.Todo) {
+		if model.Title != nil {
+			t.Title = *model.Title
+		}
+		if model.Description != nil {
+			t.Description = *model.Description
+		}
+		if model.Done != nil {
+			t.Done = *model.Done
+		}
+	}
+}
+
+// replaceFields returns a change that sets every field of model, which must
+// all be non-nil.
+func replaceFields(model models.TodoUpdateHandlerRequest) func(*models.Todo) {
+	return func(t *models.Todo) {
+		t.Title = *model.Title
+		t.Description = *model.Description
+		t.Done = *model.Done
+	}
+}
+
+func (s *TodoStore) create(t *tx, userId int, title string, description string, done bool) (models.Todo, error) {
+	var todo models.Todo
+	err := scanTodo(t.QueryRow(
+		"INSERT INTO todos(user_id, title, description, done) VALUES($1, $2, $3, $4) RETURNING "+todoColumns,
+		userId, title, description, done,
+	), &todo)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	return todo, s.recordChange(t, models.EventTodoCreated, models.Todo{}, todo)
+}
+
+// update locks the todo, applies change to a copy of it and persists the
+// result. When baseVersion is set and no longer matches the stored version,
+// nothing is written and a *ConflictError carrying the current todo is
+// returned.
+func (s *TodoStore) update(t *tx, userId int, id int, baseVersion *int, change func(*models.Todo)) (models.Todo, error) {
+	oldT, err := s.lockTodo(t, id, userId)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	if baseVersion != nil && *baseVersion != oldT.Version {
+		return models.Todo{}, &ConflictError{Current: oldT}
+	}
+
+	todo := oldT
+	change(&todo)
+
+	err = scanTodo(t.QueryRow(
+		"UPDATE todos SET title=$1, description=$2, done=$3, version=version+1 WHERE id=$4 AND user_id=$5 RETURNING "+todoColumns,
+		todo.Title, todo.Description, todo.Done, id, userId,
+	), &todo)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	return todo, s.recordChange(t, models.EventTodoUpdated, oldT, todo)
+}
+
+// remove deletes the todo, with the same baseVersion semantics as update.
+func (s *TodoStore) remove(t *tx, userId int, id int, baseVersion *int) (models.Todo, error) {
+	oldT, err := s.lockTodo(t, id, userId)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	if baseVersion != nil && *baseVersion != oldT.Version {
+		return models.Todo{}, &ConflictError{Current: oldT}
+	}
+
+	var todo models.Todo
+	err = scanTodo(t.QueryRow(
+		"DELETE FROM todos WHERE id=$1 AND user_id=$2 RETURNING "+todoColumns,
 		id, userId,
-	).Scan(&t.ID, &t.UserId, &t.Title, &t.Description, &t.CreatedAt, &t.Done)
-	if err == nil {
-		s.recordHistory(t.ID, userId, oldT, models.Todo{})
+	), &todo)
+	if err != nil {
+		return models.Todo{}, err
 	}
-	return t, err
+	return todo, s.recordChange(t, models.EventTodoDeleted, oldT, models.Todo{})
 }
@@ -0,0 +1,147 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// hashToken returns the stored form of a personal access token. Tokens are
+// random, so a fast unsalted hash is enough to keep a database dump from
+// revealing them.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePersonalToken issues a personal access token for the user. The
+// token is only ever returned here. held lists the scopes of the token the
+// request was made with, so that a token cannot issue one with more power
+// than its own. It fails with ErrValidation for unknown scopes or an expiry
+// in the past, with ErrForbidden for scopes outside held and with
+// ErrConflict when the user already has a token of that name.
+func (s *TodoStore) CreatePersonalToken(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time, held []string) (_ models.CreatedPersonalToken, err error) {
+	ctx, op := s.begin(ctx, "CreatePersonalToken")
+	defer op.end(&err)
+
+	if len(scopes) == 0 {
+		return models.CreatedPersonalToken{}, Validation("request validation failed", models.FieldError{Field: "scopes", Message: "is required"})
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return models.CreatedPersonalToken{}, Validation("request validation failed",
+				models.FieldError{Field: "scopes", Message: fmt.Sprintf("unknown scope %q", scope)})
+		}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(held, scope) {
+			return models.CreatedPersonalToken{}, Forbidden("cannot grant scope %q, which the token in use does not carry", scope)
+		}
+	}
+	scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return models.CreatedPersonalToken{}, Validation("request validation failed", models.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+
+	t := models.CreatedPersonalToken{Token: models.PersonalTokenPrefix + rand.Text()}
+	err = s.withTx(ctx, func(tx *tx) error {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM personal_tokens WHERE user_id=$1 AND name=$2)", userID, name).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return Conflict("token %q already exists", name)
+		}
+		return scanPersonalToken(tx.QueryRow(
+			"INSERT INTO personal_tokens(user_id, name, token_hash, scopes, expires_at) VALUES($1, $2, $3, $4, $5) RETURNING "+personalTokenColumns,
+			userID, name, hashToken(t.Token), pq.Array(scopes), expiresAt,
+		), &t.PersonalToken)
+	})
+	if err != nil {
+		return models.CreatedPersonalToken{}, err
+	}
+	return t, nil
+}
+
+// PersonalTokens lists the user's personal access tokens, expired ones
+// included, oldest first.
+func (s *TodoStore) PersonalTokens(ctx context.Context, userID int) (_ []models.PersonalToken, err error) {
+	ctx, op := s.begin(ctx, "PersonalTokens")
+	defer op.end(&err)
+	rows, err := s.conn(ctx).Query("SELECT "+personalTokenColumns+" FROM personal_tokens WHERE user_id=$1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalToken{}
+	for rows.Next() {
+		var t models.PersonalToken
+		if err := scanPersonalToken(rows, &t); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokePersonalToken deletes one of the user's personal access tokens.
+func (s *TodoStore) RevokePersonalToken(ctx context.Context, userID int, id int64) (err error) {
+	ctx, op := s.begin(ctx, "RevokePersonalToken")
+	defer op.end(&err)
+	res, err := s.conn(ctx).Exec("DELETE FROM personal_tokens WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return NotFound("token %d not found", id)
+	}
+	return nil
+}
+
+// VerifyPersonalToken returns the user, scopes and expiry of a personal
+// access token and records that it was used. expiresAt is nil for tokens
+// that do not expire. Unknown and expired tokens, and tokens of disabled
+// users, fail with ErrUnauthorized.
+func (s *TodoStore) VerifyPersonalToken(ctx context.Context, token string) (_ int, _ []string, _ *time.Time, err error) {
+	ctx, op := s.begin(ctx, "VerifyPersonalToken")
+	defer op.end(&err)
+	var (
+		userID    int
+		scopes    []string
+		expiresAt *time.Time
+	)
+	err = s.conn(ctx).QueryRow(
+		`UPDATE personal_tokens t SET last_used_at=NOW() FROM users u
+		WHERE t.token_hash=$1 AND u.id=t.user_id AND u.disabled_at IS NULL
+		AND (t.expires_at IS NULL OR t.expires_at > NOW())
+		RETURNING t.user_id, t.scopes, t.expires_at`,
+		hashToken(token),
+	).Scan(&userID, pq.Array(&scopes), &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, nil, nil, &Error{Kind: ErrUnauthorized, Message: "invalid token", Err: err}
+	}
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return userID, scopes, expiresAt, nil
+}
+
+// personalTokenColumns is the column list matching scanPersonalToken.
+const personalTokenColumns = "id, name, scopes, expires_at, last_used_at, created_at"
+
+func scanPersonalToken(row rowScanner, t *models.PersonalToken) error {
+	return row.Scan(&t.ID, &t.Name, pq.Array(&t.Scopes), &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	_ "github.com/lib/pq"
+)
+
+// tracer creates the spans of store methods and of the SQL statements they
+// run. It uses the global tracer provider, which records nothing until one
+// is installed.
+var tracer = otel.Tracer("ToDoProject/store")
+
+// sqlConn is satisfied by *sql.DB and *sql.Tx.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn is a querier that runs each statement with ctx in a span of its own,
+// named after the statement, e.g. "SELECT todos". The span covers executing
+// the statement, not reading the rows.
+type conn struct {
+	ctx context.Context
+	c   sqlConn
+}
+
+// conn returns a querier running statements on the pool on behalf of ctx.
+func (s *TodoStore) conn(ctx context.Context) conn {
+	return conn{ctx: ctx, c: s.DB}
+}
+
+func (c conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startStatement(c.ctx, query)
+	res, err := c.c.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
+
+func (c conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startStatement(c.ctx, query)
+	rows, err := c.c.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (c conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	ctx, span := startStatement(c.ctx, query)
+	row := c.c.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+// startStatement starts the span of a statement and records its text. The
+// store passes every value as a placeholder argument; the only text it
+// builds into statements is its own: column lists, table names, and the
+// sort column and direction of FilteredList, which are checked against
+// fixed lists. Statements therefore hold no user data and are safe to
+// export. Keep it so when adding queries.
+func startStatement(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation, collection := summarize(query)
+	name := strings.TrimSpace(operation + " " + collection)
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBQuerySummary(name),
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(query),
+	}
+	if collection != "" {
+		attrs = append(attrs, semconv.DBCollectionName(collection))
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// summarize returns the operation of a statement and the table it works on,
+// if one follows FROM, INTO or UPDATE.
+func summarize(query string) (operation, collection string) {
+	var words []string
+	for _, line := range strings.Split(query, "\n") {
+		if line, _, _ = strings.Cut(line, "--"); strings.TrimSpace(line) != "" {
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	if len(words) == 0 {
+		return "", ""
+	}
+	operation = strings.ToUpper(words[0])
+	for i, w := range words[:len(words)-1] {
+		switch strings.ToUpper(w) {
+		case "FROM", "INTO", "UPDATE":
+			return operation, strings.Trim(words[i+1], `"(),;`)
+		}
+	}
+	return operation, ""
+}
+
+// domainError reports whether err is one of the kinds callers are expected
+// to handle, such as not found or conflict, rather than a failure.
+func domainError(err error) (string, bool) {
+	for _, kind := range []error{ErrNotFound, ErrConflict, ErrValidation, ErrUnauthorized, ErrForbidden} {
+		if errors.Is(err, kind) {
+			return kind.Error(), true
+		}
+	}
+	return "", false
+}
+
+// endSpan ends span, marking it as failed when err is a failure. Domain
+// errors only set error.type, so that not found and conflict responses do
+// not show up as failed traces.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		if kind, ok := domainError(err); ok {
+			span.SetAttributes(semconv.ErrorTypeKey.String(kind))
+		} else {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	models "ToDoProject/models"
+	"context"
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+// querier is satisfied by conn, so helpers can run inside or outside of a
+// transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// tx is a transaction that remembers the outbox events and changes written
+// through it, so they can be published once the transaction has committed.
+type tx struct {
+	conn
+	events  []models.TodoEvent
+	changes []change
+}
+
+// withTx runs fn inside a transaction, committing when fn returns nil and
+// rolling back otherwise. Events and changes recorded in the transaction are
+// published after a successful commit.
+func (s *TodoStore) withTx(ctx context.Context, fn func(tx *tx) error) error {
+	sqlTx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	t := &tx{conn: conn{ctx: ctx, c: sqlTx}}
+	if err := fn(t); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return err
+	}
+	s.publish(t.events)
+	s.observeChanges(t.changes)
+	return nil
+}
@@ -3,19 +3,23 @@ package store
 import (
 	models "ToDoProject/models"
 	"ToDoProject/safety"
-	"errors"
+	"context"
+	"database/sql"
+	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-func (s *TodoStore) CreateUser(username string, password string) (models.User, error) {
-	users, errGet := s.getAllUsers()
+func (s *TodoStore) CreateUser(ctx context.Context, username string, password string) (_ models.User, err error) {
+	ctx, op := s.begin(ctx, "CreateUser")
+	defer op.end(&err)
+	users, errGet := s.getAllUsers(s.conn(ctx))
 	if errGet != nil {
 		return models.User{}, errGet
 	}
 	for _, user := range users {
 		if user.Username == username {
-			return models.User{}, errors.New("username already exists")
+			return models.User{}, Conflict("username already exists")
 		}
 	}
 
@@ -25,40 +29,25 @@ func (s *TodoStore) CreateUser(username string, password string) (models.User, e
 	}
 
 	var u models.User
-	err := s.DB.QueryRow(
+	err = s.conn(ctx).QueryRow(
 		"INSERT INTO users(username, password) VALUES($1, $2) RETURNING id, username, password",
 		username, hashedPassword,
 	).Scan(&u.ID, &u.Username, &u.Password)
 	return u, err
 }
 
-func (s *TodoStore) CheckUserCredentials(username string, password string) (int, error) {
-	users, errGet := s.getAllUsers()
-	if errGet != nil {
-		return 0, errGet
-	}
-	for _, user := range users {
-		if user.Username == username {
-			err := safety.CompareHashAndPassword([]byte(user.Password), []byte(password))
-			if err != nil {
-				return 0, errors.New("invalid password")
-			}
-			return user.ID, nil
-		}
-	}
-	return 0, errors.New("user not found")
-}
-
-func (s *TodoStore) DeleteUser(id int, password string) (models.User, error) {
-	user, errGer := s.getUserBy(id)
+func (s *TodoStore) DeleteUser(ctx context.Context, id int, password string) (_ models.User, err error) {
+	ctx, op := s.begin(ctx, "DeleteUser")
+	defer op.end(&err)
+	user, errGer := s.getUserBy(s.conn(ctx), id)
 	if errGer != nil {
 		return models.User{}, errGer
 	}
 	if user.Password != password {
-		return models.User{}, errors.New("invalid password")
+		return models.User{}, Unauthorized("invalid password")
 	}
 	var u models.User
-	err := s.DB.QueryRow(
+	err = s.conn(ctx).QueryRow(
 		"DELETE FROM users WHERE id=$1 RETURNING id, username, password",
 		id,
 	).Scan(&u.ID, &u.Username, &u.Password)
@@ -68,9 +57,48 @@ func (s *TodoStore) DeleteUser(id int, password string) (models.User, error) {
 	return u, nil
 }
 
-func (s *TodoStore) getUserBy(id int) (models.User, error) {
+// UserRoles returns the roles granted to a user.
+func (s *TodoStore) UserRoles(ctx context.Context, id int) (_ []string, err error) {
+	ctx, op := s.begin(ctx, "UserRoles")
+	defer op.end(&err)
+	var roles []string
+	err = s.conn(ctx).QueryRow("SELECT roles FROM users WHERE id=$1", id).Scan(pq.Array(&roles))
+	if err == sql.ErrNoRows {
+		return nil, &Error{Kind: ErrNotFound, Message: fmt.Sprintf("user %d not found", id), Err: err}
+	}
+	return roles, err
+}
+
+// GetUsers returns the users with the given ids, keyed by id. Unknown ids
+// are left out.
+func (s *TodoStore) GetUsers(ctx context.Context, ids []int) (_ map[int]models.User, err error) {
+	ctx, op := s.begin(ctx, "GetUsers")
+	defer op.end(&err)
+	userIDs := make([]int64, len(ids))
+	for i, id := range ids {
+		userIDs[i] = int64(id)
+	}
+
+	rows, err := s.conn(ctx).Query("SELECT id, username, password FROM users WHERE id = ANY($1)", pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[int]models.User)
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Password); err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, rows.Err()
+}
+
+func (s *TodoStore) getUserBy(q querier, id int) (models.User, error) {
 	var u models.User
-	err := s.DB.QueryRow(
+	err := q.QueryRow(
 		"SELECT id, username, password FROM users WHERE id=$1",
 		id,
 	).Scan(&u.ID, &u.Username, &u.Password)
@@ -80,8 +108,8 @@ func (s *TodoStore) getUserBy(id int) (models.User, error) {
 	return u, nil
 }
 
-func (s *TodoStore) getAllUsers() ([]models.User, error) {
-	rows, err := s.DB.Query("SELECT id, username, password FROM users ORDER BY id")
+func (s *TodoStore) getAllUsers(q querier) ([]models.User, error) {
+	rows, err := q.Query("SELECT id, username, password FROM users ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,5 @@
+// Package todopb holds the generated protobuf and gRPC code of
+// proto/todo/v1/todo.proto.
+package todopb
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=ToDoProject --go-grpc_out=.. --go-grpc_opt=module=ToDoProject todo/v1/todo.proto
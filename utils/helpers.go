@@ -36,22 +36,3 @@ func DeleteFirstById(todos []models.Todo, id int) ([]models.Todo, models.Todo) {
 	}
 	return todos, todo
 }
-
-func DetermineOrder(order *string, sort *string) string {
-	if (order == nil || *order == "") && (sort == nil || *sort == "") {
-		return " ORDER BY id"
-	}
-	var result string = " ORDER BY "
-	if sort != nil && *sort != "" {
-		result += *sort
-	} else {
-		result += " created_at"
-	}
-
-	if order != nil && *order == "desc" {
-		result += " DESC"
-	} else {
-		result += " ASC"
-	}
-	return result
-}
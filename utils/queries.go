@@ -14,6 +14,8 @@ func MakeQueriesStruct(r *http.Request) models.TodoQueries {
 		}
 	}
 	timestamp := r.URL.Query().Get("created_at")
+	createdBefore := r.URL.Query().Get("created_before")
+	createdAfter := r.URL.Query().Get("created_after")
 	title := r.URL.Query().Get("title")
 	description := r.URL.Query().Get("description")
 	order := r.URL.Query().Get("order")
@@ -21,18 +23,20 @@ func MakeQueriesStruct(r *http.Request) models.TodoQueries {
 	limit := r.URL.Query().Get("limit")
 	offset := r.URL.Query().Get("offset")
 	model := models.TodoQueries{
-		Done:        doneBool,
-		Timestamp:   &timestamp,
-		Title:       &title,
-		Description: &description,
-		Order:       &order,
-		Sort:        &sort,
-		Limit:       &limit,
-		Offset:      &offset,
+		Done:          doneBool,
+		Timestamp:     &timestamp,
+		CreatedBefore: &createdBefore,
+		CreatedAfter:  &createdAfter,
+		Title:         &title,
+		Description:   &description,
+		Order:         &order,
+		Sort:          &sort,
+		Limit:         &limit,
+		Offset:        &offset,
 	}
 	return model
 }
 
 func CheckQueries(m models.TodoQueries) bool {
-	return m.Done == nil && *m.Timestamp == "" && *m.Title == "" && *m.Order == "" && *m.Sort == "" && *m.Limit == "" && *m.Offset == "" && *m.Description == ""
+	return m.Done == nil && *m.Timestamp == "" && *m.CreatedBefore == "" && *m.CreatedAfter == "" && *m.Title == "" && *m.Order == "" && *m.Sort == "" && *m.Limit == "" && *m.Offset == "" && *m.Description == ""
 }
@@ -0,0 +1,157 @@
+// Package validate checks request payloads against rules declared in
+// `validate` struct tags, for example:
+//
+//	Title string `json:"title" validate:"trim,notblank,max=200"`
+//
+// Rules are applied in order and separated by commas:
+//
+//	trim      trims surrounding whitespace in place (needs a pointer to the struct)
+//	required  the field must be set: non-nil for pointers, non-empty for strings
+//	notblank  the string must not be empty
+//	min=N     the string must be at least N characters
+//	max=N     the string must be at most N characters
+//	username  letters, digits, '.', '_' and '-' only
+//	password  the password policy, see checkPassword
+//
+// Pointer fields that are nil skip every rule except required, so optional
+// fields of partial updates are only checked when present.
+package validate
+
+import (
+	models "ToDoProject/models"
+	"ToDoProject/store"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Request validates v like Struct and reports every failing field in one
+// store validation error, which the HTTP and gRPC APIs map to their
+// invalid-request responses.
+func Request(v interface{}) error {
+	if fields := Struct(v); len(fields) > 0 {
+		return store.Validation("request validation failed", fields...)
+	}
+	return nil
+}
+
+// Struct validates v, a struct or pointer to struct, and returns every field
+// error found. Field names are taken from the json tags.
+func Struct(v interface{}) []models.FieldError {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []models.FieldError
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		if msg := checkField(rv.Field(i), strings.Split(tag, ",")); msg != "" {
+			errs = append(errs, models.FieldError{Field: jsonName(field), Message: msg})
+		}
+	}
+	return errs
+}
+
+// checkField applies rules to one field and returns the first failure.
+func checkField(v reflect.Value, rules []string) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			for _, rule := range rules {
+				if rule == "required" {
+					return "is required"
+				}
+			}
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name == "trim" {
+			if v.Kind() == reflect.String && v.CanSet() {
+				v.SetString(strings.TrimSpace(v.String()))
+			}
+			continue
+		}
+		if v.Kind() != reflect.String {
+			continue
+		}
+
+		s := v.String()
+		switch name {
+		case "required", "notblank":
+			if strings.TrimSpace(s) == "" {
+				return "must not be empty"
+			}
+		case "min":
+			n, _ := strconv.Atoi(arg)
+			if utf8.RuneCountInString(s) < n {
+				return fmt.Sprintf("must be at least %d characters", n)
+			}
+		case "max":
+			n, _ := strconv.Atoi(arg)
+			if utf8.RuneCountInString(s) > n {
+				return fmt.Sprintf("must be at most %d characters", n)
+			}
+		case "username":
+			for _, r := range s {
+				if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-') {
+					return "may only contain letters, digits, '.', '_' and '-'"
+				}
+			}
+		case "password":
+			if msg := checkPassword(s); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+// checkPassword enforces the password policy: 8 to 72 bytes (bcrypt ignores
+// anything longer) with at least one letter and one digit.
+func checkPassword(p string) string {
+	if len(p) < 8 {
+		return "must be at least 8 characters"
+	}
+	if len(p) > 72 {
+		return "must be at most 72 bytes"
+	}
+	var letter, digit bool
+	for _, r := range p {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return "must contain at least one letter and one digit"
+	}
+	return ""
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}